@@ -121,3 +121,12 @@ type Metric interface {
 	// to any output.
 	Drop()
 }
+
+// TrackingMetric is a Metric whose delivery is tracked, see
+// TrackingAccumulator.
+type TrackingMetric interface {
+	Metric
+
+	// TrackingID returns the ID of the metric group the metric belongs to.
+	TrackingID() TrackingID
+}
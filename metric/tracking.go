@@ -129,6 +129,10 @@ func newTrackingMetricGroup(group []telegraf.Metric, fn NotifyFunc) ([]telegraf.
 	return group, d.id
 }
 
+func (m *trackingMetric) TrackingID() telegraf.TrackingID {
+	return m.d.id
+}
+
 func (m *trackingMetric) Copy() telegraf.Metric {
 	m.d.incr()
 	return &trackingMetric{
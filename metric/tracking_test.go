@@ -65,6 +65,18 @@ func TestNewTrackingID(t *testing.T) {
 	}
 }
 
+func TestTrackingMetric_TrackingID(t *testing.T) {
+	m := mustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	_, ok := m.(telegraf.TrackingMetric)
+	require.False(t, ok)
+
+	tm, id := WithTracking(m, func(telegraf.DeliveryInfo) {})
+	require.Implements(t, (*telegraf.TrackingMetric)(nil), tm)
+	require.Equal(t, id, tm.(telegraf.TrackingMetric).TrackingID())
+	require.Equal(t, id, tm.Copy().(telegraf.TrackingMetric).TrackingID())
+	tm.Drop()
+}
+
 func TestTracking(t *testing.T) {
 	tests := []struct {
 		name      string
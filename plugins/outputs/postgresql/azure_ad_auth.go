@@ -0,0 +1,44 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/jackc/pgx/v4"
+)
+
+// azureDatabaseResource is the resource access tokens are requested for, to authenticate to Azure Database for
+// PostgreSQL.
+const azureDatabaseResource = "https://ossrdbms-aad.database.windows.net"
+
+// azureADAuth returns the function setting the password of each new connection to an Azure AD access token, as per
+// azure_ad_auth. The token is refreshed when it is about to expire.
+func (p *Postgresql) azureADAuth() (func(context.Context, *pgx.ConnConfig) error, error) {
+	var spt *adal.ServicePrincipalToken
+	if p.AzureTenantID != "" {
+		oauthConfig, err := adal.NewOAuthConfig(p.AzureADEndpoint, p.AzureTenantID)
+		if err != nil {
+			return nil, err
+		}
+		spt, err = adal.NewServicePrincipalToken(*oauthConfig, p.AzureClientID, p.AzureClientSecret, azureDatabaseResource)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		spt, err = adal.NewServicePrincipalTokenFromManagedIdentity(azureDatabaseResource,
+			&adal.ManagedIdentityOptions{ClientID: p.AzureClientID})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		if err := spt.EnsureFreshWithContext(ctx); err != nil {
+			return fmt.Errorf("fetching Azure AD access token: %w", err)
+		}
+		connConfig.Password = spt.OAuthToken()
+		return nil
+	}, nil
+}
@@ -0,0 +1,50 @@
+package postgresql
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresql_azureADAuth(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/tenant/oauth2/token", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client", r.PostForm.Get("client_id"))
+		assert.Equal(t, azureDatabaseResource, r.PostForm.Get("resource"))
+		expiresOn := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token` + strconv.Itoa(requests) + `","expires_in":"3600",` +
+			`"expires_on":"` + expiresOn + `","not_before":"` + expiresOn + `","resource":"` + azureDatabaseResource + `",` +
+			`"token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	p := newPostgresql()
+	p.Connection = "host=db.postgres.database.azure.com user=telegraf@db"
+	p.AzureADAuth = true
+	p.AzureADEndpoint = server.URL
+	p.AzureTenantID = "tenant"
+	p.AzureClientID = "client"
+	p.AzureClientSecret = "secret"
+	require.NoError(t, p.Init())
+	require.NotNil(t, p.dbConfig.BeforeConnect)
+
+	// The token is fetched once, and reused by later connections until it is about to expire.
+	for i := 0; i < 2; i++ {
+		connConfig := p.dbConfig.ConnConfig.Copy()
+		require.NoError(t, p.dbConfig.BeforeConnect(ctx, connConfig))
+		assert.Equal(t, "token1", connConfig.Password)
+	}
+	assert.Equal(t, 1, requests)
+
+	p.AWSIAMAuth = true
+	assert.Error(t, p.Init())
+}
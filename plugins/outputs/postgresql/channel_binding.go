@@ -0,0 +1,207 @@
+package postgresql
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "crypto/sha512" // registers SHA-384 and SHA-512, for certificates signed with them
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/secure/precis"
+)
+
+// scramPlusMechanism is the SASL mechanism of SCRAM-SHA-256 with channel binding.
+const scramPlusMechanism = "SCRAM-SHA-256-PLUS"
+
+// requireChannelBinding returns the BeforeConnect function making each new connection authenticate with
+// SCRAM-SHA-256-PLUS, binding the authentication to the TLS connection, as per require_channel_binding. The connection
+// fails if the server doesn't authenticate it this way. before is the BeforeConnect function it's combined with, if
+// any.
+func requireChannelBinding(before func(context.Context, *pgx.ConnConfig) error) func(context.Context, *pgx.ConnConfig) error {
+	return func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		if before != nil {
+			if err := before(ctx, connConfig); err != nil {
+				return err
+			}
+		}
+		// The password is read when authenticating, so that it's the one the other BeforeConnect functions set.
+		buildFrontend := connConfig.BuildFrontend
+		connConfig.BuildFrontend = func(r io.Reader, w io.Writer) pgconn.Frontend {
+			return &channelBindingFrontend{Frontend: buildFrontend(r, w), w: w, connConfig: connConfig}
+		}
+		return nil
+	}
+}
+
+// channelBindingFrontend handles the authentication of a connection for pgconn, which doesn't support channel
+// binding. It performs the SASL exchange with the server itself, and fails any other way of authenticating. All other
+// messages are passed through to pgconn.
+type channelBindingFrontend struct {
+	pgconn.Frontend
+	w          io.Writer
+	connConfig *pgx.ConnConfig
+	bound      bool
+}
+
+func (f *channelBindingFrontend) Receive() (pgproto3.BackendMessage, error) {
+	msg, err := f.Frontend.Receive()
+	if err != nil {
+		return nil, err
+	}
+	switch msg := msg.(type) {
+	case *pgproto3.AuthenticationSASL:
+		if err := f.scramPlusAuth(msg.AuthMechanisms); err != nil {
+			return nil, fmt.Errorf("channel binding: %w", err)
+		}
+		f.bound = true
+		return f.Frontend.Receive()
+	case *pgproto3.AuthenticationCleartextPassword, *pgproto3.AuthenticationMD5Password:
+		return nil, errors.New("channel binding required, but the server requested password authentication")
+	case *pgproto3.AuthenticationOk:
+		if !f.bound {
+			return nil, errors.New("channel binding required, but the server authenticated without it")
+		}
+	}
+	return msg, nil
+}
+
+// scramPlusAuth authenticates with SCRAM-SHA-256-PLUS, using the tls-server-end-point channel binding (RFC 5929).
+func (f *channelBindingFrontend) scramPlusAuth(mechanisms []string) error {
+	supported := false
+	for _, mechanism := range mechanisms {
+		supported = supported || mechanism == scramPlusMechanism
+	}
+	if !supported {
+		return fmt.Errorf("server doesn't support %s", scramPlusMechanism)
+	}
+	tlsConn, ok := f.w.(*tls.Conn)
+	if !ok {
+		return errors.New("connection doesn't use TLS")
+	}
+	bindingData, err := tlsServerEndPoint(tlsConn.ConnectionState().PeerCertificates)
+	if err != nil {
+		return err
+	}
+
+	clientNonce := make([]byte, 18)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return err
+	}
+	const gs2Header = "p=tls-server-end-point,,"
+	clientFirstBare := "n=,r=" + base64.RawStdEncoding.EncodeToString(clientNonce)
+	initial := &pgproto3.SASLInitialResponse{AuthMechanism: scramPlusMechanism, Data: []byte(gs2Header + clientFirstBare)}
+	if _, err := f.w.Write(initial.Encode(nil)); err != nil {
+		return err
+	}
+
+	msg, err := f.Frontend.Receive()
+	if err != nil {
+		return err
+	}
+	saslContinue, ok := msg.(*pgproto3.AuthenticationSASLContinue)
+	if !ok {
+		return fmt.Errorf("expected AuthenticationSASLContinue, received %T", msg)
+	}
+	serverFirst := string(saslContinue.Data)
+	attrs := scramAttributes(serverFirst)
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, base64.RawStdEncoding.EncodeToString(clientNonce)) {
+		return errors.New("invalid SCRAM server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return fmt.Errorf("invalid SCRAM salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations <= 0 {
+		return errors.New("invalid SCRAM iteration count")
+	}
+
+	password := []byte(f.connConfig.Password)
+	if prepared, err := precis.OpaqueString.Bytes(password); err == nil {
+		password = prepared
+	}
+	saltedPassword := pbkdf2.Key(password, salt, iterations, sha256.Size, sha256.New)
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString(append([]byte(gs2Header), bindingData...)) +
+		",r=" + nonce
+	authMessage := []byte(clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof)
+
+	clientKey := scramHMAC(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	proof := scramHMAC(storedKey[:], authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	response := &pgproto3.SASLResponse{
+		Data: []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)),
+	}
+	if _, err := f.w.Write(response.Encode(nil)); err != nil {
+		return err
+	}
+
+	msg, err = f.Frontend.Receive()
+	if err != nil {
+		return err
+	}
+	saslFinal, ok := msg.(*pgproto3.AuthenticationSASLFinal)
+	if !ok {
+		return fmt.Errorf("expected AuthenticationSASLFinal, received %T", msg)
+	}
+	attrs = scramAttributes(string(saslFinal.Data))
+	if e, ok := attrs["e"]; ok {
+		return fmt.Errorf("SCRAM authentication failed: %s", e)
+	}
+	serverSignature := scramHMAC(scramHMAC(saltedPassword, []byte("Server Key")), authMessage)
+	if attrs["v"] != base64.StdEncoding.EncodeToString(serverSignature) {
+		return errors.New("invalid SCRAM server signature")
+	}
+	return nil
+}
+
+// tlsServerEndPoint returns the tls-server-end-point channel binding data of the server certificate: its hash, by the
+// hash function of its signature algorithm, or SHA-256 for MD5 and SHA-1.
+func tlsServerEndPoint(certs []*x509.Certificate) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("server presented no certificate")
+	}
+	hash := crypto.SHA256
+	switch certs[0].SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		hash = crypto.SHA384
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write(certs[0].Raw)
+	return h.Sum(nil), nil
+}
+
+// scramAttributes parses the comma separated 'name=value' attributes of a SCRAM message.
+func scramAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(msg, ",") {
+		if len(attr) >= 2 && attr[1] == '=' {
+			attrs[attr[:1]] = attr[2:]
+		}
+	}
+	return attrs
+}
+
+func scramHMAC(key, msg []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
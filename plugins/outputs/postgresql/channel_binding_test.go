@@ -0,0 +1,174 @@
+package postgresql
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// scramTestServer authenticates a client with SCRAM-SHA-256-PLUS as PostgreSQL does, over a TLS connection with a
+// self-signed certificate, returning the error the client's channelBindingFrontend received.
+func scramTestServer(t *testing.T, password string, serve func(conn *tls.Conn, cert *x509.Certificate)) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "db"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	go func() {
+		conn := tls.Server(serverConn, &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		})
+		if conn.Handshake() == nil {
+			serve(conn, cert)
+		}
+		conn.Close()
+	}()
+
+	conn := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true}) //nolint:gosec // self-signed test certificate
+	require.NoError(t, conn.Handshake())
+	connConfig := &pgx.ConnConfig{}
+	connConfig.Password = password
+	frontend := &channelBindingFrontend{
+		Frontend:   pgproto3.NewFrontend(fullChunkReader{conn}, conn),
+		w:          conn,
+		connConfig: connConfig,
+	}
+	_, err = frontend.Receive()
+	return err
+}
+
+// readSASLMessage reads a SASLInitialResponse or SASLResponse message, returning its data.
+// fullChunkReader is a pgproto3.ChunkReader reading each chunk in full.
+type fullChunkReader struct {
+	r io.Reader
+}
+
+func (cr fullChunkReader) Next(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(cr.r, buf)
+	return buf, err
+}
+
+func readSASLMessage(t *testing.T, r io.Reader, initial bool) string {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(r, header)
+	require.NoError(t, err)
+	require.Equal(t, byte('p'), header[0])
+	body := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+	_, err = io.ReadFull(r, body)
+	require.NoError(t, err)
+	if initial {
+		mechanism := body[:strings.IndexByte(string(body), 0)]
+		assert.Equal(t, scramPlusMechanism, string(mechanism))
+		body = body[len(mechanism)+5:]
+	}
+	return string(body)
+}
+
+func TestChannelBindingFrontend(t *testing.T) {
+	salt := []byte("salt")
+	saltedPassword := pbkdf2.Key([]byte("secret"), salt, 4096, sha256.Size, sha256.New)
+	hmacSHA256 := func(key []byte, msg string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(msg))
+		return mac.Sum(nil)
+	}
+
+	serve := func(conn *tls.Conn, cert *x509.Certificate) {
+		_, _ = conn.Write((&pgproto3.AuthenticationSASL{AuthMechanisms: []string{"SCRAM-SHA-256", scramPlusMechanism}}).Encode(nil))
+		clientFirst := readSASLMessage(t, conn, true)
+		const gs2Header = "p=tls-server-end-point,,"
+		if !assert.True(t, strings.HasPrefix(clientFirst, gs2Header)) {
+			return
+		}
+		clientFirstBare := strings.TrimPrefix(clientFirst, gs2Header)
+		serverFirst := "r=" + scramAttributes(clientFirstBare)["r"] + "server,s=" +
+			base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+		_, _ = conn.Write((&pgproto3.AuthenticationSASLContinue{Data: []byte(serverFirst)}).Encode(nil))
+
+		clientFinal := readSASLMessage(t, conn, false)
+		attrs := scramAttributes(clientFinal)
+		certHash := sha256.Sum256(cert.Raw)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(append([]byte(gs2Header), certHash[:]...)), attrs["c"])
+		authMessage := clientFirstBare + "," + serverFirst + "," + clientFinal[:strings.Index(clientFinal, ",p=")]
+		clientKey := hmacSHA256(saltedPassword, "Client Key")
+		storedKey := sha256.Sum256(clientKey)
+		expectedProof := hmacSHA256(storedKey[:], authMessage)
+		for i := range expectedProof {
+			expectedProof[i] ^= clientKey[i]
+		}
+		if attrs["p"] != base64.StdEncoding.EncodeToString(expectedProof) {
+			_, _ = conn.Write((&pgproto3.AuthenticationSASLFinal{Data: []byte("e=invalid-proof")}).Encode(nil))
+			return
+		}
+		serverSignature := hmacSHA256(hmacSHA256(saltedPassword, "Server Key"), authMessage)
+		finalMsg := "v=" + base64.StdEncoding.EncodeToString(serverSignature)
+		_, _ = conn.Write((&pgproto3.AuthenticationSASLFinal{Data: []byte(finalMsg)}).Encode(nil))
+		_, _ = conn.Write((&pgproto3.AuthenticationOk{}).Encode(nil))
+	}
+	require.NoError(t, scramTestServer(t, "secret", serve))
+
+	err := scramTestServer(t, "wrong", serve)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid-proof")
+
+	err = scramTestServer(t, "secret", func(conn *tls.Conn, cert *x509.Certificate) {
+		_, _ = conn.Write((&pgproto3.AuthenticationSASL{AuthMechanisms: []string{"SCRAM-SHA-256"}}).Encode(nil))
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "doesn't support")
+
+	err = scramTestServer(t, "secret", func(conn *tls.Conn, cert *x509.Certificate) {
+		_, _ = conn.Write((&pgproto3.AuthenticationMD5Password{}).Encode(nil))
+	})
+	require.Error(t, err)
+
+	err = scramTestServer(t, "secret", func(conn *tls.Conn, cert *x509.Certificate) {
+		_, _ = conn.Write((&pgproto3.AuthenticationOk{}).Encode(nil))
+	})
+	require.Error(t, err)
+}
+
+func TestPostgresql_requireChannelBinding(t *testing.T) {
+	p := newPostgresql()
+	p.RequireChannelBinding = true
+	require.NoError(t, p.Init())
+	require.NotNil(t, p.dbConfig.BeforeConnect)
+
+	connConfig := p.dbConfig.ConnConfig.Copy()
+	require.NoError(t, p.dbConfig.BeforeConnect(ctx, connConfig))
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	assert.IsType(t, &channelBindingFrontend{}, connConfig.BuildFrontend(clientConn, clientConn))
+}
@@ -0,0 +1,62 @@
+package postgresql
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// coalesceEnabled reports whether metrics are buffered across writes, as per coalesce_size and coalesce_interval.
+func (p *Postgresql) coalesceEnabled() bool {
+	return p.CoalesceSize > 0 || p.CoalesceInterval > 0
+}
+
+// coalesce buffers the metrics, to be written together with those of other writes once coalesce_size metrics are
+// buffered, or by coalesceWorker after coalesce_interval. While the buffered metrics can't be written, the metrics
+// which would fill the buffer are returned to telegraf with the error, so that the buffer stays below coalesce_size.
+func (p *Postgresql) coalesce(metrics []telegraf.Metric) error {
+	p.coalesceMutex.Lock()
+	defer p.coalesceMutex.Unlock()
+
+	// The buffered metrics are references to tracking metrics, as telegraf accepts them once buffered.
+	p.coalesced = append(p.coalesced, holdMetrics(metrics)...)
+	if len(p.coalesced) < p.CoalesceSize {
+		return nil
+	}
+	return p.flushCoalesced(len(metrics))
+}
+
+// flushCoalesced writes the buffered metrics. Must be called with coalesceMutex held. If the write fails, the metrics
+// are kept for the next flush, except for the latest n, which are left for telegraf to retry as the error is returned
+// from its write.
+func (p *Postgresql) flushCoalesced(n int) error {
+	if len(p.coalesced) == 0 {
+		return nil
+	}
+	if err := p.writeMetrics(p.coalesced); err != nil {
+		releaseMetrics(p.coalesced[len(p.coalesced)-n:])
+		p.coalesced = p.coalesced[:len(p.coalesced)-n]
+		return err
+	}
+	acceptMetrics(p.coalesced)
+	p.coalesced = nil
+	return nil
+}
+
+// coalesceWorker flushes the buffered metrics every coalesce_interval, until the plugin is closed.
+func (p *Postgresql) coalesceWorker() {
+	ticker := time.NewTicker(time.Duration(p.CoalesceInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.coalesceMutex.Lock()
+			if err := p.flushCoalesced(0); err != nil {
+				p.Logger.Errorf("write error (retrying on next flush): %v", err)
+			}
+			p.coalesceMutex.Unlock()
+		case <-p.dbContext.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package postgresql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestPostgresql_coalesceUnreachable(t *testing.T) {
+	p := newPostgresql()
+	// Nothing listens on port 1, so each write fails.
+	p.Connection = "host=127.0.0.1 port=1 connect_timeout=1 pool_max_conns=1"
+	p.CoalesceInterval = config.Duration(time.Hour)
+	require.NoError(t, p.Init())
+	p.Logger = NewLogAccumulator(t)
+	assert.Equal(t, 10000, p.CoalesceSize)
+	p.CoalesceSize = 10
+
+	p.dbContext = context.Background()
+	p.dbConfig.LazyConnect = true
+	var err error
+	p.db, err = pgxpool.ConnectConfig(p.dbContext, p.dbConfig)
+	require.NoError(t, err)
+	defer p.db.Close()
+
+	batch := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"v": 1}),
+		newMetric(t, "", nil, MSI{"v": 2}),
+		newMetric(t, "", nil, MSI{"v": 3}),
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.coalesce(batch))
+	}
+	// The writes which would fill the buffer fail, leaving their metrics to telegraf.
+	for i := 0; i < 5; i++ {
+		require.Error(t, p.coalesce(batch))
+		assert.Len(t, p.coalesced, 9)
+	}
+	require.Error(t, p.flushCoalesced(0))
+	assert.Len(t, p.coalesced, 9)
+}
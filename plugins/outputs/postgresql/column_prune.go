@@ -0,0 +1,223 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// PruneColumns drops the field columns of the metric tables written since connecting which haven't held a value
+// within column_prune_retention, using drop_column_templates. Tables without any rows within column_prune_retention are
+// left as they are. It is run every column_prune_interval, and may also be called directly, such as when using the
+// plugin as a library.
+func (p *Postgresql) PruneColumns() error {
+	if !p.isConnected() {
+		return fmt.Errorf("not connected to the database yet")
+	}
+	tm := p.tableManager
+	cutoff := p.timeValue(time.Now().UTC().Add(-time.Duration(p.ColumnPruneRetention)))
+
+	count := 0
+	for _, tbl := range tm.metricTables() {
+		n, err := tm.pruneColumns(p.dbContext, p.schemaConn(p.conn()), tbl, cutoff)
+		if err != nil {
+			if p.isTemporary(err) {
+				return err
+			}
+			// Carry on with the other tables.
+			p.Logger.Errorf("Couldn't prune columns of table '%s'\n%v", tbl.name, err)
+			continue
+		}
+		count += n
+	}
+	p.Logger.Debugf("pruned %d columns", count)
+	return nil
+}
+
+// metricTables returns the cached metric tables which exist, in order of name.
+func (tm *TableManager) metricTables() []*tableState {
+	tm.tablesMutex.Lock()
+	var tables []*tableState
+	for name, tbl := range tm.tables {
+		if tm.TagsAsForeignKeys && strings.HasSuffix(name, tm.TagTableSuffix) {
+			continue
+		}
+		tbl.RLock()
+		exists := len(tbl.columns) > 0
+		tbl.RUnlock()
+		if exists {
+			tables = append(tables, tbl)
+		}
+	}
+	tm.tablesMutex.Unlock()
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	return tables
+}
+
+// prunableColumns returns the field columns of the table structure which may be pruned, in order of name.
+func (tm *TableManager) prunableColumns(columns map[string]utils.Column) []utils.Column {
+	var fieldCols []utils.Column
+	for _, col := range columns {
+		if col.Role == utils.FieldColType && col.Name != fieldsJSONColumnName && col.Name != tm.RawColumn {
+			fieldCols = append(fieldCols, col)
+		}
+	}
+	sort.Slice(fieldCols, func(i, j int) bool { return fieldCols[i].Name < fieldCols[j].Name })
+	return fieldCols
+}
+
+// staleColumns returns those of the given field columns of the table which hold no values in the rows since cutoff.
+// If the table has no rows since cutoff, none are returned.
+func (tm *TableManager) staleColumns(
+	ctx context.Context,
+	db dbh,
+	tableName string,
+	fieldCols []utils.Column,
+	cutoff interface{},
+) ([]utils.Column, error) {
+	if len(fieldCols) == 0 {
+		return nil, nil
+	}
+
+	selectors := make([]string, 0, len(fieldCols)+1)
+	selectors = append(selectors, "count(*)")
+	for _, col := range fieldCols {
+		selectors = append(selectors, "count("+utils.QuoteIdentifier(col.Name)+")")
+	}
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE %s >= $1", strings.Join(selectors, ", "),
+		utils.FullTableName(tm.Schema, tableName).Sanitize(), utils.QuoteIdentifier(timeColumnName))
+
+	counts := make([]int64, len(selectors))
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	rows, err := db.Query(ctx, sql, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if counts[0] == 0 {
+		return nil, nil
+	}
+
+	var stale []utils.Column
+	for i, col := range fieldCols {
+		if counts[i+1] == 0 {
+			stale = append(stale, col)
+		}
+	}
+	return stale, nil
+}
+
+// pruneColumns drops the stale field columns of the metric table, returning the number of columns dropped.
+func (tm *TableManager) pruneColumns(ctx context.Context, db dbh, metricsTable *tableState, cutoff interface{}) (int, error) {
+	metricsTable.RLock()
+	fieldCols := tm.prunableColumns(metricsTable.columns)
+	metricsTable.RUnlock()
+	staleCols, err := tm.staleColumns(ctx, db, metricsTable.name, fieldCols, cutoff)
+	if err != nil || len(staleCols) == 0 {
+		return 0, err
+	}
+
+	var tagsTable *tableState
+	if tm.TagsAsForeignKeys {
+		tagsTable = tm.table(metricsTable.name + tm.TagTableSuffix)
+		// Same lock order as EnsureStructure: 1) Tag, 2) Metric
+		tagsTable.RLock()
+		defer tagsTable.RUnlock()
+	}
+	metricsTable.Lock()
+	defer metricsTable.Unlock()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+	if err := tm.lockSchema(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	// Re-checked while holding the locks, as a write may have added values since.
+	staleCols, err = tm.staleColumns(ctx, tx, metricsTable.name, tm.prunableColumns(metricsTable.columns), cutoff)
+	if err != nil || len(staleCols) == 0 {
+		return 0, err
+	}
+	names := make([]string, len(staleCols))
+	for i, col := range staleCols {
+		names[i] = col.Name
+	}
+	tm.Logger.Infof("dropping columns of table '%s' without values since %v: %s", metricsTable.name, cutoff,
+		strings.Join(names, ", "))
+
+	ddl := tm.ddlHandle(tx)
+	createView := tm.TagsAsForeignKeys && tm.CreateViews
+	if createView {
+		// A column cannot be dropped while a view depends on it.
+		if err := tm.dropView(ctx, ddl, metricsTable); err != nil {
+			return 0, err
+		}
+	}
+
+	tmplTable, metricsTmplTable, tagsTmplTable := tm.templateTables(metricsTable, metricsTable, tagsTable)
+	for _, tmpl := range tm.DropColumnTemplates {
+		sql, err := tmpl.Render(tmplTable, tm.translateColumns(staleCols), metricsTmplTable, tagsTmplTable,
+			tm.Tablespace, tm.ColumnOrder, nil)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := ddl.Exec(ctx, string(sql)); err != nil {
+			return 0, fmt.Errorf("executing `%s`: %w", sql, err)
+		}
+	}
+
+	var currCols map[string]utils.Column
+	if tm.DDLDryRun {
+		// Nothing was changed, so the structure is left as it is.
+		currCols = metricsTable.columns
+	} else if currCols, err = tm.getColumns(ctx, tx, metricsTable.name); err != nil {
+		return 0, err
+	}
+
+	if createView {
+		if err := tm.refreshView(ctx, ddl, metricsTable, tagsTable, currCols, tagsTable.columns); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tm.commitSchema(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	metricsTable.columns = currCols
+	return len(staleCols), nil
+}
+
+// columnPruneWorker prunes the columns of the metric tables every column_prune_interval, until the plugin is closed.
+func (p *Postgresql) columnPruneWorker() {
+	ticker := time.NewTicker(time.Duration(p.ColumnPruneInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.PruneColumns(); err != nil {
+				p.Logger.Errorf("Couldn't prune columns\n%v", err)
+			}
+		case <-p.dbContext.Done():
+			return
+		}
+	}
+}
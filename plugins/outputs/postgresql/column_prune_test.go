@@ -0,0 +1,89 @@
+package postgresql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestPostgresqlInit_columnPrune(t *testing.T) {
+	p := newPostgresql()
+	p.ColumnPruneInterval = config.Duration(time.Hour)
+	err := p.Init()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires column_prune_retention")
+
+	p = newPostgresql()
+	p.ColumnPruneInterval = config.Duration(time.Hour)
+	p.ColumnPruneRetention = config.Duration(24 * time.Hour)
+	p.NoDDL = true
+	require.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.ColumnPruneRetention = config.Duration(-time.Hour)
+	require.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.ColumnPruneInterval = config.Duration(time.Hour)
+	p.ColumnPruneRetention = config.Duration(24 * time.Hour)
+	require.NoError(t, p.Init())
+	require.Len(t, p.DropColumnTemplates, 1)
+}
+
+func TestPostgresql_PruneColumns(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	p.CreateViews = true
+	p.ColumnPruneRetention = config.Duration(time.Hour)
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		testutil.MustMetric(t.Name(), MSS{"tag": "foo"}, MSI{"a": 1, "b": 2}, time.Now().Add(-2*time.Hour)),
+		testutil.MustMetric(t.Name(), MSS{"tag": "foo"}, MSI{"a": 3}, time.Now()),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	require.NoError(t, p.PruneColumns())
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 2)
+	assert.Contains(t, dump[0], "a")
+	assert.NotContains(t, dump[0], "b")
+	assert.NotContains(t, p.tableManager.table(t.Name()).columns, "b")
+
+	// The view is recreated without the column.
+	dump = dbTableDump(t, p.db, p.ViewSuffix)
+	require.Len(t, dump, 2)
+	assert.Contains(t, dump[0], "tag")
+	assert.NotContains(t, dump[0], "b")
+
+	// The column is added again when the field reappears.
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 4, "b": 5}),
+	}
+	require.NoError(t, p.Write(metrics))
+	dump = dbTableDump(t, p.db, "")
+	require.Len(t, dump, 3)
+	var bValues []interface{}
+	for _, row := range dump {
+		if row["b"] != nil {
+			bValues = append(bValues, row["b"])
+		}
+	}
+	assert.EqualValues(t, []interface{}{int64(5)}, bValues)
+
+	// A table without recent rows is left as it is.
+	metrics = []telegraf.Metric{
+		testutil.MustMetric(t.Name()+"_old", MSS{"tag": "foo"}, MSI{"a": 1}, time.Now().Add(-2*time.Hour)),
+	}
+	require.NoError(t, p.Write(metrics))
+	require.NoError(t, p.PruneColumns())
+	dump = dbTableDump(t, p.db, "_old")
+	require.Len(t, dump, 1)
+	assert.Contains(t, dump[0], "a")
+}
@@ -1,6 +1,12 @@
 package postgresql
 
-import "github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
 
 // Column names and data types for standard fields (time, tag_id, tags, and fields)
 const (
@@ -13,14 +19,110 @@ const (
 	jsonColumnDataType   = PgJSONb
 )
 
-var timeColumn = utils.Column{Name: timeColumnName, Type: timeColumnDataType, Role: utils.TimeColType}
-var tagIDColumn = utils.Column{Name: tagIDColumnName, Type: tagIDColumnDataType, Role: utils.TagsIDColType}
-var fieldsJSONColumn = utils.Column{Name: fieldsJSONColumnName, Type: jsonColumnDataType, Role: utils.FieldColType}
-var tagsJSONColumn = utils.Column{Name: tagsJSONColumnName, Type: jsonColumnDataType, Role: utils.TagColType}
+// timeColumn returns the time column, which is of type timestamptz with timestamp_with_timezone, or bigint with an
+// epoch time_format.
+func (p *Postgresql) timeColumn() utils.Column {
+	if _, ok := epochUnits[p.TimeFormat]; ok {
+		return utils.Column{Name: timeColumnName, Type: PgBigInt, Role: utils.TimeColType}
+	}
+	if p.TimestampWithTimezone {
+		return utils.Column{Name: timeColumnName, Type: PgTimestampWithTimeZone, Role: utils.TimeColType}
+	}
+	return utils.Column{Name: timeColumnName, Type: timeColumnDataType, Role: utils.TimeColType}
+}
+
+// fieldsJSONColumn returns the fields column, of the data type set by json_type.
+func (p *Postgresql) fieldsJSONColumn() utils.Column {
+	return utils.Column{Name: fieldsJSONColumnName, Type: p.JSONType, Role: utils.FieldColType}
+}
+
+// tagIDColumn returns the tag_id column, of the data type set by tag_id_type.
+func (p *Postgresql) tagIDColumn() utils.Column {
+	return utils.Column{Name: tagIDColumnName, Type: p.TagIDType, Role: utils.TagsIDColType}
+}
+
+// tagsJSONColumn returns the tags column, of the data type set by json_type.
+func (p *Postgresql) tagsJSONColumn() utils.Column {
+	return utils.Column{Name: tagsJSONColumnName, Type: p.JSONType, Role: utils.TagColType}
+}
+
+// rawColumn returns the raw_column column, holding the original metric.
+func (p *Postgresql) rawColumn() utils.Column {
+	return utils.Column{Name: p.RawColumn, Type: PgJSONb, Role: utils.FieldColType}
+}
+
+// timePrecisions maps time_precision to the fractional digits of the time column, and the duration metric times are
+// truncated to.
+var timePrecisions = map[string]struct {
+	digits   int
+	truncate time.Duration
+}{
+	"s":  {0, time.Second},
+	"ms": {3, time.Millisecond},
+	"us": {6, time.Microsecond},
+}
+
+// timeColumnType returns the data type with which to create the time column, including the precision of time_precision.
+// pgType is the type without precision, as reported by information_schema.
+func (p *Postgresql) timeColumnType(pgType string) string {
+	precision, ok := timePrecisions[p.TimePrecision]
+	if !ok {
+		return pgType
+	}
+	switch pgType {
+	case PgTimestampWithTimeZone:
+		return fmt.Sprintf("timestamp(%d) with time zone", precision.digits)
+	case PgTimestampWithoutTimeZone:
+		return fmt.Sprintf("timestamp(%d) without time zone", precision.digits)
+	}
+	return pgType
+}
+
+// metricTime returns the time of the metric to write, truncated to time_precision.
+func (p *Postgresql) metricTime(metric telegraf.Metric) time.Time {
+	t := metric.Time().UTC()
+	if precision, ok := timePrecisions[p.TimePrecision]; ok {
+		t = t.Truncate(precision.truncate)
+	}
+	return t
+}
+
+// epochUnits maps the epoch values of time_format to the unit of the time column.
+var epochUnits = map[string]time.Duration{
+	"unix":    time.Second,
+	"unix_ms": time.Millisecond,
+	"unix_us": time.Microsecond,
+	"unix_ns": time.Nanosecond,
+}
+
+// metricTimeValue returns the value of the time column for the metric, which is the metric time, or with an epoch
+// time_format, the number of time_format units since the epoch.
+func (p *Postgresql) metricTimeValue(metric telegraf.Metric) interface{} {
+	return p.timeValue(p.metricTime(metric))
+}
+
+// timeValue returns the value of the time column for the time, as per time_format.
+func (p *Postgresql) timeValue(t time.Time) interface{} {
+	if unit, ok := epochUnits[p.TimeFormat]; ok {
+		return t.UnixNano() / int64(unit)
+	}
+	return t
+}
 
 func (p *Postgresql) columnFromTag(key string, value interface{}) utils.Column {
-	return utils.Column{Name: key, Type: p.derivePgDatatype(value), Role: utils.TagColType}
+	dataType := p.derivePgDatatype(value)
+	if p.UseCitext && dataType == PgText {
+		dataType = PgCitext
+	}
+	if p.isEnumTag(key) {
+		dataType = enumTypeName(key)
+	} else if s, ok := value.(string); ok && p.DetectUUIDs {
+		if _, ok := parseUUID(s); ok {
+			dataType = PgUUID
+		}
+	}
+	return utils.Column{Name: key, Type: dataType, Role: utils.TagColType}
 }
 func (p *Postgresql) columnFromField(key string, value interface{}) utils.Column {
-	return utils.Column{Name: key, Type: p.derivePgDatatype(value), Role: utils.FieldColType}
+	return utils.Column{Name: key, Type: p.fieldPgDatatype(key, value), Role: utils.FieldColType}
 }
@@ -0,0 +1,49 @@
+package postgresql
+
+import (
+	"time"
+)
+
+// isConnected reports whether Connect has connected to the database, which with lazy_connect may not be so yet.
+func (p *Postgresql) isConnected() bool {
+	select {
+	case <-p.connected:
+		return true
+	default:
+		return false
+	}
+}
+
+// connectRetryWorker retries connecting every connect_retry_interval until connected, or closed.
+func (p *Postgresql) connectRetryWorker() {
+	defer close(p.connectRetryDone)
+	ticker := time.NewTicker(time.Duration(p.ConnectRetryInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.closePools()
+			if err := p.connect(); err != nil {
+				continue
+			}
+			p.Logger.Infof("Connected to the database")
+			close(p.connected)
+			return
+		case <-p.dbContext.Done():
+			p.closePools()
+			return
+		}
+	}
+}
+
+// closePools closes the pools of a failed attempt to connect, which may have connected before a later step failed.
+func (p *Postgresql) closePools() {
+	if p.db != nil {
+		p.db.Close()
+		p.db = nil
+	}
+	if p.ddlDB != nil {
+		p.ddlDB.Close()
+		p.ddlDB = nil
+	}
+}
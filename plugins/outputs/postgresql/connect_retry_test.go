@@ -0,0 +1,37 @@
+package postgresql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestPostgresql_lazyConnect(t *testing.T) {
+	p := newPostgresql()
+	// Nothing listens on port 1, so connecting fails.
+	p.Connection = "host=127.0.0.1 port=1 connect_timeout=1"
+	p.ConnectRetryInterval = config.Duration(10 * time.Millisecond)
+	require.NoError(t, p.Init())
+	p.Logger = NewLogAccumulator(t)
+	require.Error(t, p.Connect())
+
+	p.LazyConnect = true
+	require.NoError(t, p.Connect())
+	assert.False(t, p.isConnected())
+	err := p.Write([]telegraf.Metric{newMetric(t, "", nil, MSI{"v": 1})})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not connected")
+
+	require.NoError(t, p.Close())
+	select {
+	case <-p.connectRetryDone:
+	default:
+		assert.Fail(t, "connect retry worker still running after close")
+	}
+	assert.Nil(t, p.db)
+}
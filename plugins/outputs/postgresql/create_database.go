@@ -0,0 +1,54 @@
+package postgresql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// maintenanceDatabase is the database connected to, on the server of connection, to create the database of connection.
+const maintenanceDatabase = "postgres"
+
+// isUndefinedDatabaseError reports whether the error is due to the database of the connection not existing.
+func isUndefinedDatabaseError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "3D000" // invalid_catalog_name
+}
+
+// createDatabase creates the database of connection, as per create_database, connecting to the maintenance database
+// of the server to do so. A database created meanwhile, such as by another telegraf, is not an error.
+func (p *Postgresql) createDatabase() error {
+	connConfig := p.dbConfig.ConnConfig.Copy()
+	name := connConfig.Database
+	connConfig.Database = maintenanceDatabase
+	if p.dbConfig.BeforeConnect != nil {
+		if err := p.dbConfig.BeforeConnect(p.dbContext, connConfig); err != nil {
+			return err
+		}
+	}
+	conn, err := pgx.ConnectConfig(p.dbContext, connConfig)
+	if err != nil {
+		return fmt.Errorf("connecting to the %s database: %w", maintenanceDatabase, err)
+	}
+	defer conn.Close(p.dbContext) //nolint:errcheck
+
+	sql := "CREATE DATABASE " + utils.QuoteIdentifier(name)
+	if p.CreateDatabaseTemplate != "" {
+		sql += " TEMPLATE " + utils.QuoteIdentifier(p.CreateDatabaseTemplate)
+	}
+	if _, err := p.ddlHandle(conn).Exec(p.dbContext, sql); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42P04" { // duplicate_database
+			return nil
+		}
+		return err
+	}
+	if !p.DDLDryRun {
+		p.Logger.Infof("Created database %s", name)
+	}
+	return nil
+}
@@ -1,7 +1,17 @@
 package postgresql
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
 )
 
 // Constants for naming PostgreSQL data types both in
@@ -19,6 +29,23 @@ const (
 	PgTimestampWithoutTimeZone = "timestamp without time zone"
 	PgSerial                   = "serial"
 	PgJSONb                    = "jsonb"
+	PgJSON                     = "json"
+	PgBytea                    = "bytea"
+	PgInterval                 = "interval"
+	PgUUID                     = "uuid"
+)
+
+// Types for array-valued fields.
+const (
+	PgBigIntArray          = "bigint[]"
+	PgDoublePrecisionArray = "double precision[]"
+	PgTextArray            = "text[]"
+)
+
+// Type for uint64 values, when stored as numeric with uint64_type = "numeric(20,0)". The precision is enough for any
+// uint64 value.
+const (
+	PgNumeric20 = "numeric(20,0)"
 )
 
 // Types from pguint
@@ -26,6 +53,18 @@ const (
 	PgUint8 = "uint8"
 )
 
+// Types from PostGIS. Geometry columns are reported by information_schema as 'geometry', and created as
+// PgGeometryPoint.
+const (
+	PgGeometry      = "geometry"
+	PgGeometryPoint = "geometry(Point,4326)"
+)
+
+// Types from citext
+const (
+	PgCitext = "citext"
+)
+
 // DerivePgDatatype returns the appropriate PostgreSQL data type
 // that could hold the value.
 func (p *Postgresql) derivePgDatatype(value interface{}) string {
@@ -52,9 +91,396 @@ func (p *Postgresql) derivePgDatatype(value interface{}) string {
 		return PgReal
 	case string:
 		return PgText
+	case []byte:
+		return PgBytea
 	case time.Time:
 		return PgTimestampWithoutTimeZone
+	case time.Duration:
+		return PgInterval
+	case [16]byte:
+		return PgUUID
+	case []int64:
+		return PgBigIntArray
+	case []float64:
+		return PgDoublePrecisionArray
+	case []string:
+		return PgTextArray
 	default:
 		return PgText
 	}
 }
+
+// fieldPgDatatype returns the PostgreSQL data type for the value of the given field. Unlike derivePgDatatype, floats
+// and integers are narrowed as per float_type, integer_type and narrow_fields, to the narrowest type which can hold
+// the value.
+func (p *Postgresql) fieldPgDatatype(key string, value interface{}) string {
+	if typeName := p.fieldTypeOf(key); isNumericWithModifiers(typeName) {
+		switch value.(type) {
+		case int64, uint64, float64:
+			return typeName
+		}
+	}
+
+	dataType := p.derivePgDatatype(value)
+	narrow := p.narrowFieldsFilter != nil && p.narrowFieldsFilter.Match(key)
+
+	switch dataType {
+	case PgNumeric:
+		if p.Uint64Type == PgNumeric20 {
+			return PgNumeric20
+		}
+	case PgDoublePrecision:
+		if p.FloatType != PgReal && !narrow {
+			return dataType
+		}
+		if v, ok := value.(float64); ok && math.Abs(v) <= math.MaxFloat32 {
+			return PgReal
+		}
+	case PgBigInt:
+		intType := p.IntegerType
+		if narrow && intType == PgBigInt {
+			intType = PgInteger
+		}
+		if intType == PgBigInt {
+			return dataType
+		}
+		v, ok := value.(int64)
+		if !ok {
+			return dataType
+		}
+		if intType == PgSmallInt && v >= math.MinInt16 && v <= math.MaxInt16 {
+			return PgSmallInt
+		}
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return PgInteger
+		}
+	}
+	return dataType
+}
+
+// numericTypeRe matches numeric types with a precision, and optionally scale, in field_types.
+var numericTypeRe = regexp.MustCompile(`^numeric\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)$`)
+
+// isNumericWithModifiers reports whether the data type is numeric with a precision & scale, e.g. 'numeric(12,2)'.
+func isNumericWithModifiers(pgType string) bool {
+	return strings.HasPrefix(pgType, PgNumeric+"(")
+}
+
+// fieldType is an entry of field_types, overriding the data type of the fields matching the filter.
+type fieldType struct {
+	filter   filter.Filter
+	typeName string
+}
+
+// intervalUnits maps the interval types of field_types to the unit of the field values.
+var intervalUnits = map[string]time.Duration{
+	"interval_ns": time.Nanosecond,
+	"interval_us": time.Microsecond,
+	"interval_ms": time.Millisecond,
+	"interval_s":  time.Second,
+}
+
+// parseFieldTypes parses the field_types entries, of the form "field_glob:type".
+func parseFieldTypes(entries []string) ([]fieldType, error) {
+	fieldTypes := make([]fieldType, 0, len(entries))
+	for _, entry := range entries {
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid field_types entry %q, expected \"field:type\"", entry)
+		}
+		typeName := entry[i+1:]
+		if m := numericTypeRe.FindStringSubmatch(typeName); m != nil {
+			// Normalized to the form in which getColumns reports the type of existing columns.
+			scale := m[2]
+			if scale == "" {
+				scale = "0"
+			}
+			typeName = "numeric(" + m[1] + "," + scale + ")"
+		} else if _, ok := intervalUnits[typeName]; !ok && typeName != PgUUID {
+			return nil, fmt.Errorf("invalid type in field_types entry %q", entry)
+		}
+		f, err := filter.Compile([]string{entry[:i]})
+		if err != nil {
+			return nil, fmt.Errorf("invalid field_types entry %q: %w", entry, err)
+		}
+		fieldTypes = append(fieldTypes, fieldType{filter: f, typeName: typeName})
+	}
+	return fieldTypes, nil
+}
+
+// fieldTypeOf returns the field_types type of the given field, or an empty string if it has none.
+func (p *Postgresql) fieldTypeOf(key string) string {
+	for _, ft := range p.fieldTypes {
+		if ft.filter.Match(key) {
+			return ft.typeName
+		}
+	}
+	return ""
+}
+
+// convertFieldType converts the value of a field to the type of its field_types entry. Values which cannot be
+// converted are returned as is.
+func convertFieldType(typeName string, value interface{}) interface{} {
+	if unit, ok := intervalUnits[typeName]; ok {
+		switch v := value.(type) {
+		case int64:
+			return time.Duration(v) * unit
+		case uint64:
+			return time.Duration(v) * unit
+		case float64:
+			return time.Duration(v * float64(unit))
+		}
+	}
+	if typeName == PgUUID {
+		if s, ok := value.(string); ok {
+			if uuid, ok := parseUUID(s); ok {
+				return uuid
+			}
+		}
+	}
+	return value
+}
+
+// parseUUID parses a UUID in its canonical form, e.g. "123e4567-e89b-12d3-a456-426614174000".
+func parseUUID(s string) ([16]byte, bool) {
+	var uuid [16]byte
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return uuid, false
+	}
+	hexStr := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(uuid[:], []byte(hexStr)); err != nil {
+		return uuid, false
+	}
+	return uuid, true
+}
+
+// Policies for uint64 values exceeding the range of bigint, when using uint64_type = "bigint".
+const (
+	uint64OverflowClamp   = "clamp"
+	uint64OverflowNumeric = "numeric"
+	uint64OverflowText    = "text"
+	uint64OverflowDrop    = "drop"
+)
+
+// Policies for NaN and ±Inf float values.
+const (
+	nonFiniteKeep     = "keep"
+	nonFiniteNull     = "null"
+	nonFiniteSentinel = "sentinel"
+	nonFiniteDrop     = "drop"
+)
+
+// Policies for string values exceeding max_string_length.
+const (
+	stringLengthTruncate = "truncate"
+	stringLengthDrop     = "drop"
+)
+
+// limitFieldValue handles NaN and ±Inf floats according to non_finite_floats, and strings longer than
+// max_string_length according to string_length_policy. Unlike the rest of fieldValue, this also applies to the
+// fields of fields_as_jsonb. The returned bool is false if the field should be dropped.
+func (p *Postgresql) limitFieldValue(value interface{}) (interface{}, bool) {
+	if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		switch p.NonFiniteFloats {
+		case nonFiniteNull:
+			return nil, true
+		case nonFiniteSentinel:
+			return p.NonFiniteSentinel, true
+		case nonFiniteDrop:
+			return nil, false
+		default:
+			return value, true
+		}
+	}
+
+	if s, ok := value.(string); ok && p.MaxStringLength > 0 && len(s) > p.MaxStringLength {
+		// The length is in characters, as with varchar(n). The byte length is checked first as it is cheaper, and is
+		// never less than the character length.
+		if runes := []rune(s); len(runes) > p.MaxStringLength {
+			if p.StringLengthPolicy == stringLengthDrop {
+				return nil, false
+			}
+			return string(runes[:p.MaxStringLength]), true
+		}
+	}
+	return value, true
+}
+
+// fieldValue returns the value to write for the given field value. With uint64_type = "bigint", uint64 values are
+// converted to int64, and values exceeding its range are handled according to uint64_overflow. NaN and ±Inf floats
+// are handled according to non_finite_floats, and strings longer than max_string_length according to
+// string_length_policy. Fields with a field_types entry are converted to its type, and string fields matching
+// bytea_fields are decoded from base64. The returned bool is false if the field should be dropped. A nil value
+// is written as NULL, in the column of the original value's type.
+func (p *Postgresql) fieldValue(key string, value interface{}) (interface{}, bool) {
+	if typeName := p.fieldTypeOf(key); typeName != "" {
+		value = convertFieldType(typeName, value)
+	} else if p.DetectUUIDs {
+		value = convertFieldType(PgUUID, value)
+	}
+	if s, ok := value.(string); ok && p.byteaFieldsFilter != nil && p.byteaFieldsFilter.Match(key) {
+		// Values which aren't valid base64 are written as text, which conflicts with the bytea column's type.
+		if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+			return b, true
+		}
+	}
+
+	value, ok := p.limitFieldValue(value)
+	if !ok {
+		return nil, false
+	}
+
+	v, ok := value.(uint64)
+	if !ok || p.Uint64Type != PgBigInt {
+		return value, true
+	}
+	if v <= math.MaxInt64 {
+		return int64(v), true
+	}
+	switch p.Uint64Overflow {
+	case uint64OverflowNumeric:
+		return v, true
+	case uint64OverflowText:
+		return strconv.FormatUint(v, 10), true
+	case uint64OverflowDrop:
+		return nil, false
+	default:
+		return int64(math.MaxInt64), true
+	}
+}
+
+// telegrafDatatype returns the name of the telegraf value type from which the
+// given PostgreSQL data type is derived.
+func telegrafDatatype(pgType string) string {
+	if pgType != PgNumeric20 && isNumericWithModifiers(pgType) {
+		return "numeric"
+	}
+	if strings.HasSuffix(pgType, "[]") {
+		return telegrafDatatype(strings.TrimSuffix(pgType, "[]")) + "[]"
+	}
+	switch pgType {
+	case PgBool:
+		return "boolean"
+	case PgNumeric, PgUint8:
+		return "unsigned"
+	case PgBigInt, PgInteger, PgSmallInt:
+		return "integer"
+	case PgDoublePrecision, PgReal:
+		return "float"
+	case PgText, PgCitext:
+		return "string"
+	case PgJSONb, PgJSON:
+		return "json"
+	case PgBytea:
+		return "bytes"
+	case PgInterval:
+		return "duration"
+	case PgUUID:
+		return "uuid"
+	default:
+		return pgType
+	}
+}
+
+// translateColumns returns the columns with their data types translated to those used when creating the columns.
+// Column types are otherwise kept as reported by information_schema (e.g. 'geometry' rather than
+// 'geometry(Point,4326)'), so that derived and existing types can be compared.
+func (p *Postgresql) translateColumns(cols []utils.Column) []utils.Column {
+	cols = p.dialect.translateColumns(cols)
+	newCols := make([]utils.Column, len(cols))
+	for i, col := range cols {
+		switch {
+		case col.Role == utils.TimeColType:
+			col.Type = p.timeColumnType(col.Type)
+		case col.Role == utils.TagColType && p.isEnumTag(col.Name):
+			col.Type = utils.FullTableName(p.Schema, col.Type).Sanitize()
+		case col.Type == PgGeometry:
+			col.Type = PgGeometryPoint
+		}
+		newCols[i] = col
+	}
+	return newCols
+}
+
+// columnAccepts reports whether a column of type colType can hold values of type valType, either directly, or after
+// being widened with widen_column_templates.
+func (p *Postgresql) columnAccepts(colType, valType string) bool {
+	if colType == valType || pgDatatypeHolds(colType, valType) {
+		return true
+	}
+	if (colType == PgNumeric || isNumericWithModifiers(colType)) && (valType == PgNumeric || isNumericWithModifiers(valType)) {
+		// An unconstrained numeric column holds any numeric value, and unconstrained numeric values (uint64 with
+		// uint64_type = "numeric") are written to numeric columns of any precision. Otherwise the precision & scale
+		// must match.
+		return colType == PgNumeric || valType == PgNumeric
+	}
+	return len(p.WidenColumnTemplates) > 0 && widenPgDatatype(colType, valType) != ""
+}
+
+// pgDatatypeWidening lists the data types a column may be widened through, from narrowest to widest.
+var pgDatatypeWidening = []string{PgSmallInt, PgInteger, PgBigInt, PgReal, PgDoublePrecision, PgText}
+
+func pgDatatypeWideningRank(pgType string) int {
+	for i, t := range pgDatatypeWidening {
+		if t == pgType {
+			return i
+		}
+	}
+	return -1
+}
+
+// pgDatatypeHolds reports whether a column of type colType holds values of the narrower type valType without loss.
+// A real only holds integers exactly up to 2^24, so it does not hold integer or bigint values.
+func pgDatatypeHolds(colType, valType string) bool {
+	colRank, valRank := pgDatatypeWideningRank(colType), pgDatatypeWideningRank(valType)
+	if colRank < 0 || valRank < 0 || colRank <= valRank {
+		return false
+	}
+	return colType != PgReal || valType == PgSmallInt
+}
+
+// widenPgDatatype returns the data type which a column of type colType must be altered to in order to hold values of
+// type valType. If the column does not need widening, or cannot be safely widened, an empty string is returned.
+func widenPgDatatype(colType, valType string) string {
+	colRank, valRank := pgDatatypeWideningRank(colType), pgDatatypeWideningRank(valType)
+	if colRank < 0 || valRank < 0 || colType == valType || pgDatatypeHolds(colType, valType) {
+		return ""
+	}
+	newType := valType
+	if valRank < colRank {
+		// a real column receiving integer or bigint values
+		newType = colType
+	}
+	if newType == PgReal && (colType == PgInteger || colType == PgBigInt || valType == PgInteger || valType == PgBigInt) {
+		newType = PgDoublePrecision
+	}
+	return newType
+}
+
+// convertFieldValue converts a field value to the data type of the column it's written to, where the column has been
+// widened to a type which pgx won't convert the value to itself.
+func convertFieldValue(value interface{}, colType string) interface{} {
+	switch colType {
+	case PgText, PgCitext:
+		switch v := value.(type) {
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case uint64:
+			return strconv.FormatUint(v, 10)
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		case float32:
+			return strconv.FormatFloat(float64(v), 'g', -1, 32)
+		case bool:
+			return strconv.FormatBool(v)
+		}
+	case PgDoublePrecision:
+		switch v := value.(type) {
+		case int64:
+			return float64(v)
+		case uint64:
+			return float64(v)
+		}
+	}
+	return value
+}
@@ -0,0 +1,102 @@
+package postgresql
+
+import (
+	"bytes"
+	"context"
+	"os"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+// deadLetterColumns are the columns written to dead_letter_table. The table also has a time column, defaulting to the
+// time the metrics were dropped.
+var deadLetterColumns = []string{"measurement", "metric", "error"}
+
+// ensureDeadLetterTable creates dead_letter_table if it does not exist.
+func (p *Postgresql) ensureDeadLetterTable() error {
+	sql := "CREATE TABLE IF NOT EXISTS " + utils.FullTableName(p.Schema, p.DeadLetterTable).Sanitize() +
+		" (time timestamptz NOT NULL DEFAULT now(), measurement text NOT NULL, metric jsonb, error text NOT NULL)"
+	_, err := p.ddlHandle(p.db).Exec(p.dbContext, sql)
+	return err
+}
+
+// deadLetter records the metrics, which are being dropped due to the permanent error writeErr,
+// so that they can be inspected and replayed. With dead_letter_table, each metric is written to the table through db,
+// within a transaction (or savepoint when db is a transaction). With dead_letter_file, the metrics are appended to the
+// file in line protocol. Failing to record the metrics is only logged, as they are being dropped regardless.
+func (p *Postgresql) deadLetter(ctx context.Context, db dbh, metrics []telegraf.Metric, writeErr error) {
+	if p.DeadLetterTable != "" {
+		var rows [][]interface{}
+		for _, metric := range metrics {
+			// Metrics which can't be serialized, such as due to NaN fields, are still recorded, without the metric.
+			var raw interface{}
+			if b, err := utils.MetricToJSON(metric); err == nil {
+				raw = b
+			}
+			rows = append(rows, []interface{}{metric.Name(), raw, writeErr.Error()})
+		}
+		if len(rows) > 0 {
+			if err := p.writeDeadLetters(ctx, db, rows); err != nil {
+				p.Logger.Errorf("Couldn't write %d dropped metrics to dead letter table '%s'\n%v",
+					len(rows), p.DeadLetterTable, err)
+			}
+		}
+	}
+
+	if p.DeadLetterFile != "" {
+		if err := p.appendDeadLetterFile(metrics); err != nil {
+			p.Logger.Errorf("Couldn't write dropped metrics to dead letter file %s\n%v", p.DeadLetterFile, err)
+		}
+	}
+}
+
+func (p *Postgresql) writeDeadLetters(ctx context.Context, db dbh, rows [][]interface{}) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	ident := utils.FullTableName(p.Schema, p.DeadLetterTable)
+	if err := p.copyFrom(ctx, tx, ident, deadLetterColumns, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// appendDeadLetterFile appends the metrics to dead_letter_file, in line protocol, so that they can
+// be replayed such as with the file input.
+func (p *Postgresql) appendDeadLetterFile(metrics []telegraf.Metric) error {
+	var buf bytes.Buffer
+	serializer := influx.NewSerializer()
+	serializer.SetFieldTypeSupport(influx.UintSupport)
+	for _, metric := range metrics {
+		b, err := serializer.Serialize(metric)
+		if err != nil {
+			// Such as a metric without fields, which can't be replayed anyway.
+			p.Logger.Debugf("Couldn't serialize dropped metric: %v", err)
+			continue
+		}
+		buf.Write(b)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	// Workers dropping sub-batches concurrently would otherwise interleave their writes.
+	p.deadLetterFileMutex.Lock()
+	defer p.deadLetterFileMutex.Unlock()
+	f, err := os.OpenFile(p.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close() //nolint:errcheck
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,290 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// Dialects of the PostgreSQL compatible databases which are supported. The dialect determines the default templates,
+// and works around features the database lacks.
+const (
+	dialectPostgreSQL = "postgresql"
+	dialectYugabyteDB = "yugabytedb"
+	dialectGreenplum  = "greenplum"
+	dialectQuestDB    = "questdb"
+	dialectAlloyDB    = "alloydb"
+)
+
+// dialect describes how a database differs from PostgreSQL.
+type dialect struct {
+	// Default templates, used when the corresponding template option is not set. Empty values use the PostgreSQL
+	// defaults.
+	createTemplate            string
+	addColumnTemplate         string
+	tagTableCreateTemplate    string
+	tagTableAddColumnTemplate string
+
+	// noGeneratedColumns is set for databases predating generated columns (PostgreSQL 12).
+	noGeneratedColumns bool
+	// noOnConflict is set for databases predating 'INSERT ... ON CONFLICT' (PostgreSQL 9.5).
+	noOnConflict bool
+	// noTransactions is set for databases which don't support transactions. This also precludes advisory locks and
+	// temp tables.
+	noTransactions bool
+	// noSchemas is set for databases without schemas. Tables are referenced by name only.
+	noSchemas bool
+	// noCopy is set for databases which don't support 'COPY ... FROM STDIN'. Metrics are written with INSERT instead.
+	noCopy bool
+	// noInformationSchema is set for databases without information_schema, or column comments. The table structure is
+	// read with 'SHOW COLUMNS' instead, and tag columns are identified by being of type tagType.
+	noInformationSchema bool
+	// columnarEngine is set for databases with the AlloyDB columnar engine, to which metric tables can be added.
+	columnarEngine bool
+
+	// fieldTypes translates PostgreSQL data types of fields (and the time column) to the types of the dialect, and
+	// pgTypes translates the types of the dialect back. tagType is the type of tag columns. All are empty when the
+	// dialect uses the PostgreSQL types.
+	fieldTypes map[string]string
+	pgTypes    map[string]string
+	tagType    string
+}
+
+var dialects = map[string]dialect{
+	dialectPostgreSQL: {},
+	// AlloyDB is PostgreSQL compatible, and additionally has a columnar engine which can accelerate analytic queries.
+	dialectAlloyDB: {
+		columnarEngine: true,
+	},
+	// Tables are hash sharded, and split into tablets automatically. The tag table is sharded on tag_id explicitly, as
+	// the primary key is otherwise range sharded.
+	dialectYugabyteDB: {
+		tagTableCreateTemplate: `CREATE TABLE {{.table}} ({{.columns}}, PRIMARY KEY (tag_id HASH))`,
+		noGeneratedColumns:     true,
+	},
+	// Metric tables are append-optimized & column-oriented, for analytic workloads. They are distributed on tag_id so
+	// that joins with the tag table are local to each segment. The defaults are compatible with Greenplum 6, which is
+	// based on PostgreSQL 9.4.
+	dialectGreenplum: {
+		createTemplate: `CREATE TABLE {{.table}} ({{.columns}}) WITH (appendoptimized=true, orientation=column) ` +
+			`DISTRIBUTED {{if .tagTable}}BY (tag_id){{else}}RANDOMLY{{end}}`,
+		addColumnTemplate:         `ALTER TABLE {{.table}} ADD COLUMN {{.columns|join ", ADD COLUMN "}}`,
+		tagTableCreateTemplate:    `CREATE TABLE {{.table}} ({{.columns}}, PRIMARY KEY (tag_id)) DISTRIBUTED BY (tag_id)`,
+		tagTableAddColumnTemplate: `ALTER TABLE {{.table}} ADD COLUMN {{.columns|join ", ADD COLUMN "}}`,
+		noGeneratedColumns:        true,
+		noOnConflict:              true,
+	},
+	// QuestDB is a time series database implementing the PostgreSQL wire protocol, but little else. Tables are
+	// partitioned by day on the time column, and tags are stored as the SYMBOL type.
+	dialectQuestDB: {
+		createTemplate:      `CREATE TABLE {{.table}} ({{.columns}}) timestamp(time) PARTITION BY DAY`,
+		addColumnTemplate:   `ALTER TABLE {{.table}} ADD COLUMN {{.columns|join ", "}}`,
+		noGeneratedColumns:  true,
+		noOnConflict:        true,
+		noTransactions:      true,
+		noSchemas:           true,
+		noCopy:              true,
+		noInformationSchema: true,
+		fieldTypes: map[string]string{
+			PgBool:                     "boolean",
+			PgSmallInt:                 "short",
+			PgInteger:                  "int",
+			PgBigInt:                   "long",
+			PgReal:                     "float",
+			PgDoublePrecision:          "double",
+			PgNumeric:                  "double",
+			PgText:                     "string",
+			PgCitext:                   "string",
+			PgJSONb:                    "string",
+			PgJSON:                     "string",
+			PgBytea:                    "binary",
+			PgInterval:                 "long",
+			PgUUID:                     "uuid",
+			PgTimestampWithTimeZone:    "timestamp",
+			PgTimestampWithoutTimeZone: "timestamp",
+		},
+		pgTypes: map[string]string{
+			"boolean":   PgBool,
+			"byte":      PgSmallInt,
+			"short":     PgSmallInt,
+			"int":       PgInteger,
+			"long":      PgBigInt,
+			"float":     PgReal,
+			"double":    PgDoublePrecision,
+			"string":    PgText,
+			"symbol":    PgText,
+			"timestamp": PgTimestampWithoutTimeZone,
+		},
+		tagType: "symbol",
+	},
+}
+
+// translateColumns returns a copy of the columns with the data types translated to those of the dialect.
+func (d dialect) translateColumns(cols []utils.Column) []utils.Column {
+	if d.fieldTypes == nil {
+		return cols
+	}
+	newCols := make([]utils.Column, len(cols))
+	for i, col := range cols {
+		if col.Role == utils.TagColType && col.Name != tagsJSONColumnName {
+			col.Type = d.tagType
+		} else if t, ok := d.fieldTypes[col.Type]; ok {
+			col.Type = t
+		}
+		newCols[i] = col
+	}
+	return newCols
+}
+
+// noTxDB wraps a dbh for databases which don't support transactions. Begin returns a pgx.Tx which executes statements
+// immediately, and for which Commit and Rollback do nothing.
+type noTxDB struct {
+	dbh
+}
+
+func (db noTxDB) Begin(context.Context) (pgx.Tx, error) {
+	return noTx{db: db.dbh}, nil
+}
+
+// noTx is the pgx.Tx returned by noTxDB. Statements are executed through the wrapped dbh. Methods which it cannot
+// serve return errNoTxUnsupported.
+type noTx struct {
+	db dbh
+}
+
+var errNoTxUnsupported = errors.New("not supported by databases without transactions")
+
+func (tx noTx) Begin(context.Context) (pgx.Tx, error) {
+	return tx, nil
+}
+
+func (tx noTx) BeginFunc(_ context.Context, f func(pgx.Tx) error) error {
+	return f(tx)
+}
+
+func (tx noTx) Commit(context.Context) error {
+	return nil
+}
+
+func (tx noTx) Rollback(context.Context) error {
+	return nil
+}
+
+func (tx noTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return tx.db.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (tx noTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if db, ok := tx.db.(interface {
+		SendBatch(context.Context, *pgx.Batch) pgx.BatchResults
+	}); ok {
+		return db.SendBatch(ctx, b)
+	}
+	return errBatchResults{errNoTxUnsupported}
+}
+
+// LargeObjects is not supported, and returns a LargeObjects whose methods fail.
+func (tx noTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (tx noTx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, errNoTxUnsupported
+}
+
+func (tx noTx) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	return tx.db.Exec(ctx, sql, arguments...)
+}
+
+func (tx noTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return tx.db.Query(ctx, sql, args...)
+}
+
+func (tx noTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	rows, err := tx.db.Query(ctx, sql, args...)
+	return queryRow{rows: rows, err: err}
+}
+
+func (tx noTx) QueryFunc(
+	ctx context.Context,
+	sql string,
+	args []interface{},
+	scans []interface{},
+	f func(pgx.QueryFuncRow) error,
+) (pgconn.CommandTag, error) {
+	rows, err := tx.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := rows.Scan(scans...); err != nil {
+			return nil, err
+		}
+		if err := f(rows); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rows.CommandTag(), nil
+}
+
+// Conn returns nil, as the statements are not executed on a connection of the transaction's own.
+func (tx noTx) Conn() *pgx.Conn {
+	return nil
+}
+
+// queryRow is the pgx.Row of the first row of rows, as returned by QueryRow.
+type queryRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r queryRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+// errBatchResults is the pgx.BatchResults of a batch which could not be sent.
+type errBatchResults struct {
+	err error
+}
+
+func (br errBatchResults) Exec() (pgconn.CommandTag, error) {
+	return nil, br.err
+}
+
+func (br errBatchResults) Query() (pgx.Rows, error) {
+	return nil, br.err
+}
+
+func (br errBatchResults) QueryRow() pgx.Row {
+	return queryRow{err: br.err}
+}
+
+func (br errBatchResults) QueryFunc([]interface{}, func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	return nil, br.err
+}
+
+func (br errBatchResults) Close() error {
+	return br.err
+}
@@ -0,0 +1,100 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgconn"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// enumTypeName returns the name of the enum type of the given tag, as per enum_tags. The type is shared by the tag's
+// columns in all tables.
+func enumTypeName(tagKey string) string {
+	return tagKey + "_enum"
+}
+
+// isEnumTag reports whether the given tag is stored as an enum type.
+func (p *Postgresql) isEnumTag(key string) bool {
+	return p.enumTagsFilter != nil && p.enumTagsFilter.Match(key)
+}
+
+// ensureEnumValues creates the enum types of the source's enum tag columns, and adds any of the source's tag values
+// which the types do not yet have. The values known to have been added are cached. db must not be a transaction, as
+// the values have to be committed before the metrics are written.
+func (tm *TableManager) ensureEnumValues(ctx context.Context, db dbh, rowSource *TableSource) error {
+	for _, col := range rowSource.TagColumns() {
+		if !tm.isEnumTag(col.Name) {
+			continue
+		}
+
+		var values []string
+		seen := map[string]bool{}
+		for _, metric := range rowSource.metrics {
+			value, ok := metric.GetTag(col.Name)
+			if !ok || seen[value] || tm.enumValueKnown(col.Name, value) {
+				continue
+			}
+			seen[value] = true
+			values = append(values, value)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		sort.Strings(values)
+
+		if err := tm.addEnumValues(ctx, db, col.Name, values); err != nil {
+			return fmt.Errorf("adding values to enum type of tag %s: %w", col.Name, err)
+		}
+	}
+	return nil
+}
+
+func (tm *TableManager) enumValueKnown(tagKey string, value string) bool {
+	tm.enumValuesMutex.Lock()
+	defer tm.enumValuesMutex.Unlock()
+	return tm.enumValues[tagKey][value]
+}
+
+// addEnumValues creates the enum type of the tag if it does not exist, and adds the values to it. Each statement is
+// committed on its own when db is a connection pool, as a value added to an enum type cannot be used until committed.
+func (tm *TableManager) addEnumValues(ctx context.Context, db dbh, tagKey string, values []string) error {
+	typeName := utils.FullTableName(tm.Schema, enumTypeName(tagKey)).Sanitize()
+	ddl := tm.ddlHandle(db)
+
+	if !tm.enumTypeKnown(tagKey) {
+		if _, err := ddl.Exec(ctx, "CREATE TYPE "+typeName+" AS ENUM ()"); err != nil {
+			var pgErr *pgconn.PgError
+			// duplicate_object, as the type already exists
+			if !errors.As(err, &pgErr) || pgErr.Code != "42710" {
+				return err
+			}
+		}
+	}
+	for _, value := range values {
+		if _, err := ddl.Exec(ctx, "ALTER TYPE "+typeName+" ADD VALUE IF NOT EXISTS "+sqltemplate.QuoteLiteral(value)); err != nil {
+			return err
+		}
+	}
+
+	tm.enumValuesMutex.Lock()
+	defer tm.enumValuesMutex.Unlock()
+	if tm.enumValues[tagKey] == nil {
+		tm.enumValues[tagKey] = map[string]bool{}
+	}
+	for _, value := range values {
+		tm.enumValues[tagKey][value] = true
+	}
+	return nil
+}
+
+func (tm *TableManager) enumTypeKnown(tagKey string) bool {
+	tm.enumValuesMutex.Lock()
+	defer tm.enumValuesMutex.Unlock()
+	_, ok := tm.enumValues[tagKey]
+	return ok
+}
@@ -0,0 +1,64 @@
+package postgresql
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// healthCheckWorker pings the database every health_check_interval. After health_check_max_failures consecutive
+// failures, the idle connections of the pools are closed, as they're presumably dead, so that the next write
+// reconnects rather than failing on them.
+func (p *Postgresql) healthCheckWorker() {
+	ticker := time.NewTicker(time.Duration(p.HealthCheckInterval))
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.healthCheck(); err != nil {
+				if p.dbContext.Err() != nil {
+					return
+				}
+				failures++
+				p.Logger.Warnf("Health check failed (%d of %d): %v", failures, p.HealthCheckMaxFailures, err)
+				if failures >= p.HealthCheckMaxFailures {
+					ctx, cancel := context.WithTimeout(p.dbContext, time.Duration(p.HealthCheckInterval))
+					n := p.resetPool(ctx, p.db)
+					if p.ddlDB != nil {
+						n += p.resetPool(ctx, p.ddlDB)
+					}
+					cancel()
+					p.Logger.Warnf("Closed %d idle connections after %d failed health checks", n, failures)
+					failures = 0
+				}
+				continue
+			}
+			if failures > 0 {
+				p.Logger.Infof("Health check succeeded after %d failures", failures)
+				failures = 0
+			}
+		case <-p.dbContext.Done():
+			return
+		}
+	}
+}
+
+// healthCheck pings the database, with a timeout of health_check_interval.
+func (p *Postgresql) healthCheck() error {
+	ctx, cancel := context.WithTimeout(p.dbContext, time.Duration(p.HealthCheckInterval))
+	defer cancel()
+	return p.db.Ping(ctx)
+}
+
+// resetPool closes the idle connections of the pool, returning how many were closed. Connections in use are left to
+// their writes, whose errors discard them.
+func (p *Postgresql) resetPool(ctx context.Context, db *pgxpool.Pool) int {
+	conns := db.AcquireAllIdle(ctx)
+	for _, conn := range conns {
+		conn.Conn().Close(ctx) //nolint:errcheck // the connection is discarded either way
+		conn.Release()
+	}
+	return len(conns)
+}
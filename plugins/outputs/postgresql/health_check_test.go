@@ -0,0 +1,40 @@
+package postgresql
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestPostgresql_healthCheckWorker(t *testing.T) {
+	p := newPostgresql()
+	// Nothing listens on port 1, so each ping fails.
+	p.Connection = "host=127.0.0.1 port=1 connect_timeout=1"
+	p.HealthCheckInterval = config.Duration(10 * time.Millisecond)
+	p.HealthCheckMaxFailures = 2
+	require.NoError(t, p.Init())
+	logger := NewLogAccumulator(t)
+	p.Logger = logger
+
+	p.dbContext, p.dbContextCancel = context.WithCancel(context.Background())
+	defer p.dbContextCancel()
+	p.dbConfig.LazyConnect = true
+	var err error
+	p.db, err = pgxpool.ConnectConfig(p.dbContext, p.dbConfig)
+	require.NoError(t, err)
+	defer p.db.Close()
+
+	go p.healthCheckWorker()
+	logger.WaitFor(func(l Log) bool {
+		return strings.Contains(l.String(), "Health check failed (2 of 2)")
+	}, false)
+	logger.WaitFor(func(l Log) bool {
+		return strings.Contains(l.String(), "idle connections after 2 failed health checks")
+	}, false)
+}
@@ -0,0 +1,77 @@
+package postgresql
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// migrationsTableName is the table, within the configured schema, which records the migrations which have been applied.
+const migrationsTableName = "schema_migrations"
+
+// applyMigrations applies the SQL files within migrations_dir which have not yet been applied, in lexical order of their
+// file names. Each file is applied within its own transaction, and is recorded in the schema_migrations table by file
+// name.
+func (p *Postgresql) applyMigrations() error {
+	files, err := filepath.Glob(filepath.Join(p.MigrationsDir, "*.sql"))
+	if err != nil {
+		return fmt.Errorf("listing migrations: %w", err)
+	}
+	sort.Strings(files)
+
+	tableName := utils.FullTableName(p.Schema, migrationsTableName).Sanitize()
+	sql := "CREATE TABLE IF NOT EXISTS " + tableName + " (version text PRIMARY KEY, applied_at timestamptz NOT NULL DEFAULT now())"
+	if _, err := p.db.Exec(p.dbContext, sql); err != nil {
+		return fmt.Errorf("creating %s: %w", tableName, err)
+	}
+
+	for _, file := range files {
+		if err := p.applyMigration(tableName, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Postgresql) applyMigration(tableName string, file string) error {
+	version := filepath.Base(file)
+
+	tx, err := p.db.Begin(p.dbContext)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(p.dbContext) //nolint:errcheck
+	// Other telegraf processes may be applying the same migrations, or modifying the schema.
+	if err := p.lockSchema(p.dbContext, tx); err != nil {
+		return err
+	}
+
+	var applied bool
+	row := tx.QueryRow(p.dbContext, "SELECT EXISTS (SELECT 1 FROM "+tableName+" WHERE version=$1)", version)
+	if err := row.Scan(&applied); err != nil {
+		return fmt.Errorf("checking migration %s: %w", version, err)
+	}
+	if applied {
+		return nil
+	}
+
+	sql, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading migration %s: %w", version, err)
+	}
+	if _, err := tx.Exec(p.dbContext, string(sql)); err != nil {
+		return fmt.Errorf("applying migration %s: %w", version, err)
+	}
+	if _, err := tx.Exec(p.dbContext, "INSERT INTO "+tableName+" (version) VALUES ($1)", version); err != nil {
+		return fmt.Errorf("recording migration %s: %w", version, err)
+	}
+
+	if err := tx.Commit(p.dbContext); err != nil {
+		return err
+	}
+	p.Logger.Infof("Applied migration %s", version)
+	return nil
+}
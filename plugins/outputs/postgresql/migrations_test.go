@@ -0,0 +1,52 @@
+package postgresql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+func TestPostgresqlConnect_migrations(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.Schema = t.Name()
+	p.MigrationsDir = t.TempDir()
+	require.NoError(t, p.Init())
+
+	tableName := utils.FullTableName(p.Schema, t.Name()).Sanitize()
+	require.NoError(t, os.WriteFile(filepath.Join(p.MigrationsDir, "001_create.sql"),
+		[]byte("CREATE TABLE "+tableName+" (time timestamptz, a bigint)"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(p.MigrationsDir, "002_alter.sql"),
+		[]byte("ALTER TABLE "+tableName+" ADD COLUMN b bigint"), 0600))
+	require.NoError(t, p.Connect())
+	defer p.db.Exec(ctx, "DROP SCHEMA "+utils.QuoteIdentifier(t.Name())+" CASCADE") //nolint:errcheck
+
+	var versions []string
+	rows, err := p.db.Query(ctx, "SELECT version FROM "+utils.FullTableName(p.Schema, migrationsTableName).Sanitize()+" ORDER BY version")
+	require.NoError(t, err)
+	for rows.Next() {
+		var version string
+		require.NoError(t, rows.Scan(&version))
+		versions = append(versions, version)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"001_create.sql", "002_alter.sql"}, versions)
+
+	// Already applied migrations must not be applied again.
+	require.NoError(t, p.Close())
+	require.NoError(t, p.Connect())
+
+	// Fields without a column are omitted, as no DDL is generated.
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"a": 1, "c": 3}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Contains(t, tsrc.ColumnNames(), "a")
+	assert.NotContains(t, tsrc.ColumnNames(), "c")
+}
@@ -0,0 +1,25 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// passwordFileAuth returns the function setting the password of each new connection to the contents of
+// password_file. The file is read when connecting, rather than when telegraf starts, so that a password rotated by
+// whatever writes the file, such as a secret manager's agent, is used by new connections without a restart.
+func (p *Postgresql) passwordFileAuth() func(context.Context, *pgx.ConnConfig) error {
+	return func(_ context.Context, connConfig *pgx.ConnConfig) error {
+		b, err := os.ReadFile(p.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("reading password_file: %w", err)
+		}
+		// Files are commonly written with a trailing newline, which is not part of the password.
+		connConfig.Password = strings.TrimRight(string(b), "\r\n")
+		return nil
+	}
+}
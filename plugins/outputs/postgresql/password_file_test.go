@@ -0,0 +1,39 @@
+package postgresql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresql_passwordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("secret\n"), 0600))
+
+	p := newPostgresql()
+	p.Connection = "host=db.example.com user=telegraf password=ignored"
+	p.PasswordFile = path
+	require.NoError(t, p.Init())
+	require.NotNil(t, p.dbConfig.BeforeConnect)
+
+	connConfig := p.dbConfig.ConnConfig.Copy()
+	require.NoError(t, p.dbConfig.BeforeConnect(ctx, connConfig))
+	assert.Equal(t, "secret", connConfig.Password)
+
+	// The file is read for each connection, so a rotated password is used by new connections.
+	require.NoError(t, os.WriteFile(path, []byte("rotated"), 0600))
+	connConfig = p.dbConfig.ConnConfig.Copy()
+	require.NoError(t, p.dbConfig.BeforeConnect(ctx, connConfig))
+	assert.Equal(t, "rotated", connConfig.Password)
+
+	require.NoError(t, os.Remove(path))
+	require.Error(t, p.dbConfig.BeforeConnect(ctx, p.dbConfig.ConnConfig.Copy()))
+
+	p = newPostgresql()
+	p.PasswordFile = path
+	p.AWSIAMAuth = true
+	require.Error(t, p.Init())
+}
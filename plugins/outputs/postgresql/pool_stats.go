@@ -0,0 +1,50 @@
+package postgresql
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// recordPoolStats sets the internal stats of the pool, tagged with the database and pool ("write" or "ddl"), from
+// its statistics. The counts of acquires, and the time spent acquiring, are cumulative.
+func (p *Postgresql) recordPoolStats(db *pgxpool.Pool, pool string) {
+	tags := map[string]string{"database": p.dbConfig.ConnConfig.Database, "pool": pool}
+	stat := db.Stat()
+	fields := []struct {
+		field string
+		value int64
+	}{
+		{"acquired_conns", int64(stat.AcquiredConns())},
+		{"idle_conns", int64(stat.IdleConns())},
+		{"constructing_conns", int64(stat.ConstructingConns())},
+		{"total_conns", int64(stat.TotalConns())},
+		{"max_conns", int64(stat.MaxConns())},
+		{"acquire_count", stat.AcquireCount()},
+		{"empty_acquire_count", stat.EmptyAcquireCount()},
+		{"canceled_acquire_count", stat.CanceledAcquireCount()},
+		{"acquire_duration_ns", stat.AcquireDuration().Nanoseconds()},
+	}
+	for _, f := range fields {
+		selfstat.Register("postgresql_pool", f.field, tags).Set(f.value)
+	}
+}
+
+// poolStatsWorker records the statistics of the pools every pool_stats_interval.
+func (p *Postgresql) poolStatsWorker() {
+	ticker := time.NewTicker(time.Duration(p.PoolStatsInterval))
+	defer ticker.Stop()
+	for {
+		p.recordPoolStats(p.db, "write")
+		if p.ddlDB != nil {
+			p.recordPoolStats(p.ddlDB, "ddl")
+		}
+		select {
+		case <-ticker.C:
+		case <-p.dbContext.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package postgresql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+func TestPostgresql_recordPoolStats(t *testing.T) {
+	p := newPostgresql()
+	p.Connection = "host=127.0.0.1 port=1 dbname=" + t.Name() + " pool_max_conns=3"
+	require.NoError(t, p.Init())
+	p.dbConfig.LazyConnect = true
+	db, err := pgxpool.ConnectConfig(context.Background(), p.dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+
+	p.recordPoolStats(db, "write")
+	fields := map[string]interface{}{}
+	for _, m := range selfstat.Metrics() {
+		if m.Name() == "internal_postgresql_pool" && m.Tags()["database"] == t.Name() && m.Tags()["pool"] == "write" {
+			fields = m.Fields()
+		}
+	}
+	assert.Equal(t, int64(3), fields["max_conns"])
+	assert.Equal(t, int64(0), fields["total_conns"])
+	assert.Contains(t, fields, "acquire_duration_ns")
+	assert.Contains(t, fields, "canceled_acquire_count")
+}
@@ -0,0 +1,63 @@
+package postgresql
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// postWrite executes the post_write_templates of the table of a successfully written sub-batch, unless they were
+// executed for the table within post_write_interval. Failures are only logged, as the metrics have been written.
+// Templates which render to an empty statement are skipped, so that they may apply to only some tables.
+func (p *Postgresql) postWrite(ctx context.Context, tableSource *TableSource) {
+	tmpls := p.tableTemplates(tableSource.Name()).PostWriteTemplates
+	if len(tmpls) == 0 || !p.postWriteDue(tableSource.Name()) {
+		return
+	}
+
+	tm := p.tableManager
+	metricsTable := tm.table(tableSource.Name())
+	var tagsTable *tableState
+	if p.TagsAsForeignKeys {
+		tagsTable = tm.table(tableSource.Name() + p.TagTableSuffix)
+		// Same lock order as EnsureStructure: 1) Tag, 2) Metric
+		tagsTable.RLock()
+		defer tagsTable.RUnlock()
+	}
+	metricsTable.RLock()
+	defer metricsTable.RUnlock()
+
+	tmplTable, metricsTmplTable, tagsTmplTable := tm.templateTables(metricsTable, metricsTable, tagsTable)
+	columns := tm.translateColumns(colMapToSlice(metricsTable.columns))
+	metric := tableSource.SampleMetric()
+	for _, tmpl := range tmpls {
+		sql, err := tmpl.Render(tmplTable, columns, metricsTmplTable, tagsTmplTable, p.Tablespace, p.ColumnOrder, metric)
+		if err != nil {
+			p.Logger.Errorf("rendering post-write template for table %s: %v", tableSource.Name(), err)
+			continue
+		}
+		if strings.TrimSpace(string(sql)) == "" {
+			continue
+		}
+		if _, err := p.ddlHandle(p.conn()).Exec(ctx, string(sql)); err != nil {
+			p.Logger.Errorf("executing post-write statement `%s` for table %s: %v", sql, tableSource.Name(), err)
+		}
+	}
+}
+
+// postWriteDue reports whether the post_write_templates of the table are due to be executed, as per
+// post_write_interval, and if so, records that they are being executed.
+func (p *Postgresql) postWriteDue(table string) bool {
+	p.postWriteMutex.Lock()
+	defer p.postWriteMutex.Unlock()
+
+	now := time.Now()
+	if last, ok := p.postWriteLast[table]; ok && now.Sub(last) < time.Duration(p.PostWriteInterval) {
+		return false
+	}
+	if p.postWriteLast == nil {
+		p.postWriteLast = make(map[string]time.Time)
+	}
+	p.postWriteLast[table] = now
+	return true
+}
@@ -0,0 +1,71 @@
+package postgresql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+func TestPostgresql_postWriteDue(t *testing.T) {
+	p := newPostgresql()
+	p.PostWriteInterval = config.Duration(time.Hour)
+	require.NoError(t, p.Init())
+
+	assert.True(t, p.postWriteDue("cpu"))
+	assert.False(t, p.postWriteDue("cpu"))
+	assert.True(t, p.postWriteDue("mem"))
+
+	p.postWriteLast["cpu"] = time.Now().Add(-time.Hour)
+	assert.True(t, p.postWriteDue("cpu"))
+
+	p = newPostgresql()
+	require.NoError(t, p.Init())
+	assert.True(t, p.postWriteDue("cpu"))
+	assert.True(t, p.postWriteDue("cpu"))
+
+	p = newPostgresql()
+	p.PostWriteInterval = config.Duration(-time.Second)
+	require.Error(t, p.Init())
+}
+
+func TestPostgresqlWrite_postWriteTemplates(t *testing.T) {
+	p := newPostgresqlTest(t)
+	tmplSkip := &sqltemplate.Template{}
+	require.NoError(t, tmplSkip.UnmarshalText([]byte(`{{ if eq .table.Name "other" }}SELECT 1/0{{ end }}`)))
+	tmplComment := &sqltemplate.Template{}
+	require.NoError(t, tmplComment.UnmarshalText([]byte(
+		`COMMENT ON TABLE {{ .table }} IS {{ printf "columns: %d" (len .allColumns.List) | quoteLiteral }}`)))
+	p.PostWriteTemplates = []*sqltemplate.Template{tmplSkip, tmplComment}
+	p.PostWriteInterval = config.Duration(time.Hour)
+	require.NoError(t, p.Connect())
+
+	comment := func() string {
+		var comment string
+		row := p.db.QueryRow(ctx, "SELECT obj_description($1::regclass)",
+			utils.FullTableName(p.Schema, t.Name()).Sanitize())
+		require.NoError(t, row.Scan(&comment))
+		return comment
+	}
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	assert.Equal(t, "columns: 3", comment())
+	assert.False(t, p.Logger.HasLevel(pgx.LogLevelError))
+
+	// Within post_write_interval, the statements aren't executed again.
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1, "b": 2}),
+	}
+	require.NoError(t, p.Write(metrics))
+	assert.Equal(t, "columns: 3", comment())
+}
@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/coocood/freecache"
+
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 )
@@ -21,6 +23,42 @@ func BenchmarkPostgresql_concurrent(b *testing.B) {
 	benchmarkPostgresql(b, gen, 10, true)
 }
 
+// BenchmarkTableSource and BenchmarkTagTableSource measure building the rows of a batch, which with the rows reused
+// from rowPool allocate little beyond the values themselves. Run with -benchmem to see the allocations.
+func BenchmarkTableSource(b *testing.B) {
+	p := newPostgresql()
+	_ = p.Init()
+	batch := <-batchGenerator(batchGeneratorArgs{ctx, b, 1000, 1, 8, 12, 100, 2})
+	tsrc := NewTableSources(p, batch)[b.Name()+"_0"]
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tsrc.Reset()
+		for tsrc.Next() {
+			_, _ = tsrc.Values()
+		}
+	}
+}
+
+func BenchmarkTagTableSource(b *testing.B) {
+	p := newPostgresql()
+	p.TagsAsForeignKeys = true
+	_ = p.Init()
+	p.tagsCache = freecache.NewCache(1024 * 1024)
+	batch := <-batchGenerator(batchGeneratorArgs{ctx, b, 1000, 1, 8, 12, 100, 2})
+	ttsrc := NewTagTableSource(NewTableSources(p, batch)[b.Name()+"_0"])
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ttsrc.Reset()
+		for ttsrc.Next() {
+			_, _ = ttsrc.Values()
+		}
+	}
+}
+
 func benchmarkPostgresql(b *testing.B, gen <-chan []telegraf.Metric, concurrency int, foreignTags bool) {
 	p := newPostgresqlTest(b)
 	p.Connection += fmt.Sprintf(" pool_max_conns=%d", concurrency)
@@ -4,28 +4,39 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/influxdata/toml"
 
 	"github.com/influxdata/telegraf/testutil"
 
+	"github.com/cespare/xxhash/v2"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
 	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
 type Log struct {
@@ -290,6 +301,431 @@ func TestPostgresqlSampleConfig(t *testing.T) {
 	assert.JSONEq(t, string(p1json), string(p2json), "Sample config does not match default config")
 }
 
+func TestPostgresqlInit_dialect(t *testing.T) {
+	p := newPostgresql()
+	p.Dialect = dialectYugabyteDB
+	require.NoError(t, p.Init())
+	tmpl := (*template.Template)(p.TagTableCreateTemplates[0]).Root.String()
+	assert.Contains(t, tmpl, "PRIMARY KEY (tag_id HASH)")
+
+	p = newPostgresql()
+	p.Dialect = dialectGreenplum
+	require.NoError(t, p.Init())
+	tmpl = (*template.Template)(p.CreateTemplates[0]).Root.String()
+	assert.Contains(t, tmpl, "orientation=column")
+	tmpl = (*template.Template)(p.AddColumnTemplates[0]).Root.String()
+	assert.NotContains(t, tmpl, "IF NOT EXISTS")
+
+	p = newPostgresql()
+	p.Dialect = dialectGreenplum
+	p.Upsert = true
+	require.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.Dialect = dialectQuestDB
+	require.NoError(t, p.Init())
+	assert.Empty(t, p.Schema)
+	tmpl = (*template.Template)(p.CreateTemplates[0]).Root.String()
+	assert.Contains(t, tmpl, "PARTITION BY DAY")
+
+	p = newPostgresql()
+	p.Dialect = dialectQuestDB
+	p.TagsAsForeignKeys = true
+	require.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.Dialect = dialectAlloyDB
+	p.ColumnarEngine = true
+	require.NoError(t, p.Init())
+
+	p = newPostgresql()
+	p.ColumnarEngine = true
+	require.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.Dialect = "foo"
+	require.Error(t, p.Init())
+}
+
+func TestPostgresql_fieldValue(t *testing.T) {
+	p := newPostgresql()
+	require.NoError(t, p.Init())
+	v, ok := p.fieldValue("a", uint64(math.MaxUint64))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(math.MaxUint64), v)
+
+	p.Uint64Type = PgBigInt
+	v, _ = p.fieldValue("a", uint64(1))
+	assert.Equal(t, int64(1), v)
+	v, _ = p.fieldValue("a", uint64(math.MaxUint64))
+	assert.Equal(t, int64(math.MaxInt64), v)
+
+	p.Uint64Overflow = uint64OverflowNumeric
+	v, _ = p.fieldValue("a", uint64(math.MaxUint64))
+	assert.Equal(t, uint64(math.MaxUint64), v)
+
+	p.Uint64Overflow = uint64OverflowText
+	v, _ = p.fieldValue("a", uint64(math.MaxUint64))
+	assert.Equal(t, "18446744073709551615", v)
+
+	p.Uint64Overflow = uint64OverflowDrop
+	_, ok = p.fieldValue("a", uint64(math.MaxUint64))
+	assert.False(t, ok)
+
+	p = newPostgresql()
+	p.Uint64Overflow = "foo"
+	require.Error(t, p.Init())
+}
+
+func TestPostgresql_fieldValue_maxStringLength(t *testing.T) {
+	p := newPostgresql()
+	p.MaxStringLength = 3
+	require.NoError(t, p.Init())
+	v, _ := p.fieldValue("a", "abc")
+	assert.Equal(t, "abc", v)
+	v, _ = p.fieldValue("a", "abcd")
+	assert.Equal(t, "abc", v)
+	// Length is in characters, not bytes.
+	v, _ = p.fieldValue("a", "äöü")
+	assert.Equal(t, "äöü", v)
+	v, _ = p.fieldValue("a", "äöüß")
+	assert.Equal(t, "äöü", v)
+
+	p.StringLengthPolicy = stringLengthDrop
+	_, ok := p.fieldValue("a", "abcd")
+	assert.False(t, ok)
+}
+
+func TestPostgresql_fieldValue_bytea(t *testing.T) {
+	p := newPostgresql()
+	p.ByteaFields = []string{"payload"}
+	require.NoError(t, p.Init())
+	v, _ := p.fieldValue("payload", "AAEC")
+	assert.Equal(t, []byte{0, 1, 2}, v)
+	assert.Equal(t, PgBytea, p.fieldPgDatatype("payload", v))
+	v, _ = p.fieldValue("payload", "not base64!")
+	assert.Equal(t, "not base64!", v)
+	v, _ = p.fieldValue("a", "AAEC")
+	assert.Equal(t, "AAEC", v)
+}
+
+func TestPostgresql_fieldValue_fieldTypes(t *testing.T) {
+	p := newPostgresql()
+	p.FieldTypes = []string{"*_ns:interval_ns", "uptime:interval_s"}
+	require.NoError(t, p.Init())
+	v, _ := p.fieldValue("wait_ns", int64(1500))
+	assert.Equal(t, 1500*time.Nanosecond, v)
+	assert.Equal(t, PgInterval, p.fieldPgDatatype("wait_ns", v))
+	v, _ = p.fieldValue("uptime", 1.5)
+	assert.Equal(t, 1500*time.Millisecond, v)
+	v, _ = p.fieldValue("uptime", "foo")
+	assert.Equal(t, "foo", v)
+	v, _ = p.fieldValue("a", int64(1))
+	assert.Equal(t, int64(1), v)
+
+	p = newPostgresql()
+	p.FieldTypes = []string{"uptime:interval_h"}
+	require.Error(t, p.Init())
+}
+
+func TestWidenPgDatatype(t *testing.T) {
+	assert.Equal(t, PgInteger, widenPgDatatype(PgSmallInt, PgInteger))
+	assert.Equal(t, PgReal, widenPgDatatype(PgSmallInt, PgReal))
+	// integers above 2^24 aren't exact as real
+	assert.Equal(t, PgDoublePrecision, widenPgDatatype(PgInteger, PgReal))
+	assert.Equal(t, PgDoublePrecision, widenPgDatatype(PgBigInt, PgReal))
+	assert.Equal(t, PgDoublePrecision, widenPgDatatype(PgReal, PgBigInt))
+	assert.Equal(t, PgText, widenPgDatatype(PgBigInt, PgText))
+	assert.Equal(t, "", widenPgDatatype(PgDoublePrecision, PgBigInt))
+	assert.Equal(t, "", widenPgDatatype(PgReal, PgSmallInt))
+	assert.Equal(t, "", widenPgDatatype(PgBigInt, PgBool))
+}
+
+func TestPostgresql_fieldPgDatatype_numeric(t *testing.T) {
+	p := newPostgresql()
+	p.FieldTypes = []string{"price:numeric(12, 2)", "count:numeric(10)"}
+	require.NoError(t, p.Init())
+	assert.Equal(t, "numeric(12,2)", p.fieldPgDatatype("price", 1.5))
+	assert.Equal(t, "numeric(10,0)", p.fieldPgDatatype("count", int64(1)))
+	assert.Equal(t, PgText, p.fieldPgDatatype("price", "foo"))
+
+	assert.True(t, p.columnAccepts("numeric(12,2)", "numeric(12,2)"))
+	assert.True(t, p.columnAccepts(PgNumeric, "numeric(12,2)"))
+	assert.True(t, p.columnAccepts("numeric(12,2)", PgNumeric))
+	assert.False(t, p.columnAccepts("numeric(10,2)", "numeric(12,2)"))
+
+	p = newPostgresql()
+	p.FieldTypes = []string{"price:numeric(a,b)"}
+	require.Error(t, p.Init())
+}
+
+func TestPostgresql_fieldValue_uuid(t *testing.T) {
+	uuid := [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+
+	p := newPostgresql()
+	p.FieldTypes = []string{"id:uuid"}
+	require.NoError(t, p.Init())
+	v, _ := p.fieldValue("id", "123e4567-e89b-12d3-a456-426614174000")
+	assert.Equal(t, uuid, v)
+	assert.Equal(t, PgUUID, p.fieldPgDatatype("id", v))
+	v, _ = p.fieldValue("a", "123e4567-e89b-12d3-a456-426614174000")
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", v)
+
+	p.DetectUUIDs = true
+	v, _ = p.fieldValue("a", "123e4567-e89b-12d3-a456-426614174000")
+	assert.Equal(t, uuid, v)
+	v, _ = p.fieldValue("a", "123e4567-e89b-12d3-a456-42661417400z")
+	assert.Equal(t, "123e4567-e89b-12d3-a456-42661417400z", v)
+	assert.Equal(t, PgUUID, p.columnFromTag("a", "123e4567-e89b-12d3-a456-426614174000").Type)
+	assert.Equal(t, PgText, p.columnFromTag("a", "foo").Type)
+}
+
+func TestPostgresql_fieldValue_nonFinite(t *testing.T) {
+	p := newPostgresql()
+	require.NoError(t, p.Init())
+	v, ok := p.fieldValue("a", math.Inf(1))
+	assert.True(t, ok)
+	assert.Equal(t, math.Inf(1), v)
+
+	p.NonFiniteFloats = nonFiniteNull
+	v, ok = p.fieldValue("a", math.NaN())
+	assert.True(t, ok)
+	assert.Nil(t, v)
+
+	p.NonFiniteFloats = nonFiniteSentinel
+	p.NonFiniteSentinel = -1
+	v, _ = p.fieldValue("a", math.Inf(-1))
+	assert.Equal(t, -1.0, v)
+	v, _ = p.fieldValue("a", 1.5)
+	assert.Equal(t, 1.5, v)
+
+	p.NonFiniteFloats = nonFiniteDrop
+	_, ok = p.fieldValue("a", math.NaN())
+	assert.False(t, ok)
+}
+
+func TestPostgresql_metricTime(t *testing.T) {
+	p := newPostgresql()
+	require.NoError(t, p.Init())
+	m := newMetric(t, "", nil, MSI{"a": 1})
+	m.SetTime(time.Unix(1, 123456789))
+	assert.Equal(t, time.Unix(1, 123456789).UTC(), p.metricTime(m))
+
+	p.TimePrecision = "ms"
+	assert.Equal(t, time.Unix(1, 123000000).UTC(), p.metricTime(m))
+	p.TimePrecision = "s"
+	assert.Equal(t, time.Unix(1, 0).UTC(), p.metricTime(m))
+
+	p = newPostgresql()
+	p.TimePrecision = "m"
+	require.Error(t, p.Init())
+}
+
+func TestPostgresql_metricTimeValue_epoch(t *testing.T) {
+	p := newPostgresql()
+	require.NoError(t, p.Init())
+	m := newMetric(t, "", nil, MSI{"a": 1})
+	m.SetTime(time.Unix(1, 123456789))
+	assert.Equal(t, time.Unix(1, 123456789).UTC(), p.metricTimeValue(m))
+
+	p.TimeFormat = "unix"
+	assert.Equal(t, int64(1), p.metricTimeValue(m))
+	p.TimeFormat = "unix_ms"
+	assert.Equal(t, int64(1123), p.metricTimeValue(m))
+	p.TimeFormat = "unix_ns"
+	assert.Equal(t, int64(1123456789), p.metricTimeValue(m))
+	assert.Equal(t, PgBigInt, p.timeColumn().Type)
+
+	p = newPostgresql()
+	p.TimeFormat = "unix"
+	p.TimestampWithTimezone = true
+	require.Error(t, p.Init())
+}
+
+func TestPostgresql_tagIDHash(t *testing.T) {
+	m := newMetric(t, "", MSS{"a": "1", "b": "2"}, MSI{"v": 1})
+
+	p := newPostgresql()
+	require.NoError(t, p.Init())
+	assert.Equal(t, "fnv64a", p.TagIDHash)
+	assert.Equal(t, PgBigInt, p.TagIDType)
+	assert.Equal(t, tagID{lo: utils.GetTagID(m)}, p.tagID(m))
+
+	p = newPostgresql()
+	p.TagIDHash = "xxhash64"
+	require.NoError(t, p.Init())
+	assert.Equal(t, tagID{lo: int64(xxhash.Sum64String("a\x001\x00b\x002\x00"))}, p.tagID(m))
+	assert.NotEqual(t, tagID{lo: utils.GetTagID(m)}, p.tagID(m))
+
+	p = newPostgresql()
+	p.TagIDHash = "fnv128a"
+	require.NoError(t, p.Init())
+	assert.Equal(t, PgUUID, p.TagIDType)
+	h := fnv.New128a()
+	_, _ = h.Write([]byte("a\x001\x00b\x002\x00"))
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	assert.Equal(t, b, p.tagID(m).bytes())
+
+	p = newPostgresql()
+	p.TagIDHash = "md5"
+	require.Error(t, p.Init())
+
+	// 128-bit IDs don't fit in a bigint, and only 128-bit IDs are stored as uuid or numeric.
+	p = newPostgresql()
+	p.TagIDHash = "fnv128a"
+	p.TagIDType = PgBigInt
+	require.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.TagIDType = PgNumeric
+	require.Error(t, p.Init())
+}
+
+func TestPostgresql_tagIDValue(t *testing.T) {
+	id := tagID{hi: 1<<63 | 2, lo: -3}
+	for _, tc := range []struct {
+		hash   string
+		typ    string
+		id     tagID
+		value  interface{}
+		parsed string
+	}{
+		{"fnv64a", PgBigInt, tagID{lo: -3}, int64(-3), "-3"},
+		{"fnv128a", PgUUID, id, id.bytes(), "80000000-0000-0002-ffff-fffffffffffd"},
+		{"fnv128a", PgNumeric, id, "170141183460469231787027535937012760573", "170141183460469231787027535937012760573"},
+	} {
+		t.Run(tc.typ, func(t *testing.T) {
+			p := newPostgresql()
+			p.TagIDHash = tc.hash
+			p.TagIDType = tc.typ
+			require.NoError(t, p.Init())
+			assert.Equal(t, tc.value, p.tagIDValue(tc.id))
+			parsed, err := p.parseTagID(tc.parsed)
+			require.NoError(t, err)
+			assert.Equal(t, tc.id, parsed)
+			_, err = p.parseTagID("x")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPostgresql_fieldPgDatatype(t *testing.T) {
+	p := newPostgresql()
+	p.NarrowFields = []string{"narrow_*"}
+	require.NoError(t, p.Init())
+	assert.Equal(t, PgDoublePrecision, p.fieldPgDatatype("a", 1.5))
+	assert.Equal(t, PgBigInt, p.fieldPgDatatype("a", int64(1)))
+	assert.Equal(t, PgReal, p.fieldPgDatatype("narrow_a", 1.5))
+	assert.Equal(t, PgInteger, p.fieldPgDatatype("narrow_a", int64(1)))
+	assert.Equal(t, PgBigInt, p.fieldPgDatatype("narrow_a", int64(math.MaxInt64)))
+
+	p.FloatType = PgReal
+	p.IntegerType = PgSmallInt
+	assert.Equal(t, PgReal, p.fieldPgDatatype("a", 1.5))
+	assert.Equal(t, PgDoublePrecision, p.fieldPgDatatype("a", math.MaxFloat64))
+	assert.Equal(t, PgSmallInt, p.fieldPgDatatype("a", int64(1)))
+	assert.Equal(t, PgInteger, p.fieldPgDatatype("a", int64(math.MaxInt16+1)))
+
+	p = newPostgresql()
+	p.IntegerType = "tinyint"
+	require.Error(t, p.Init())
+}
+
+func TestPostgresql_fieldPgDatatype_arrays(t *testing.T) {
+	p := newPostgresql()
+	require.NoError(t, p.Init())
+	assert.Equal(t, PgBigIntArray, p.fieldPgDatatype("a", []int64{1, 2}))
+	assert.Equal(t, PgDoublePrecisionArray, p.fieldPgDatatype("a", []float64{1.5}))
+	assert.Equal(t, PgTextArray, p.fieldPgDatatype("a", []string{"foo"}))
+	assert.Equal(t, "integer[]", telegrafDatatype(PgBigIntArray))
+	assert.Equal(t, "float[]", telegrafDatatype(PgDoublePrecisionArray))
+}
+
+func TestDialect_translateColumns(t *testing.T) {
+	cols := []utils.Column{
+		{Name: timeColumnName, Type: PgTimestampWithTimeZone, Role: utils.TimeColType},
+		{Name: "host", Type: PgText, Role: utils.TagColType},
+		{Name: "value", Type: PgDoublePrecision, Role: utils.FieldColType},
+	}
+	assert.Equal(t, cols, dialects[dialectPostgreSQL].translateColumns(cols))
+
+	translated := dialects[dialectQuestDB].translateColumns(cols)
+	assert.Equal(t, "timestamp", translated[0].Type)
+	assert.Equal(t, "symbol", translated[1].Type)
+	assert.Equal(t, "double", translated[2].Type)
+	assert.Equal(t, PgText, cols[1].Type)
+}
+
+// queryErrorDB is a dbh whose queries fail with err.
+type queryErrorDB struct {
+	dbh
+	err error
+}
+
+func (db queryErrorDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return nil, db.err
+}
+
+func TestNoTx(t *testing.T) {
+	queryErr := errors.New("query failed")
+	tx, err := noTxDB{dbh: queryErrorDB{err: queryErr}}.Begin(ctx)
+	require.NoError(t, err)
+
+	var v int
+	assert.Equal(t, queryErr, tx.QueryRow(ctx, "SELECT 1").Scan(&v))
+	_, err = tx.QueryFunc(ctx, "SELECT 1", nil, []interface{}{&v}, func(pgx.QueryFuncRow) error { return nil })
+	assert.Equal(t, queryErr, err)
+	_, err = tx.Prepare(ctx, "stmt", "SELECT 1")
+	assert.Equal(t, errNoTxUnsupported, err)
+	_, err = tx.SendBatch(ctx, &pgx.Batch{}).Exec()
+	assert.Equal(t, errNoTxUnsupported, err)
+	assert.Nil(t, tx.Conn())
+}
+
+func TestShowColumnsError(t *testing.T) {
+	cols, err := showColumnsError(&pgconn.PgError{Code: "42P01"})
+	require.NoError(t, err)
+	assert.Empty(t, cols)
+
+	_, err = showColumnsError(&pgconn.PgError{Code: "42501", Message: "table does not exist"})
+	assert.Error(t, err)
+}
+
+func TestIsTempError_yugabyteDB(t *testing.T) {
+	assert.True(t, isTempError(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, isTempError(&pgconn.PgError{Code: "XX000", Message: "Try again: Leader not ready to serve requests"}))
+	assert.False(t, isTempError(&pgconn.PgError{Code: "XX000", Message: "internal error"}))
+}
+
+func TestPostgresql_isTemporary(t *testing.T) {
+	p := newPostgresql()
+	p.TemporaryErrorCodes = []string{"XX001"}
+	p.PermanentErrorCodes = []string{"xx001"}
+	require.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.TemporaryErrorCodes = []string{"XX001", "0a", "53300"}
+	p.PermanentErrorCodes = []string{"53"}
+	require.NoError(t, p.Init())
+	assert.True(t, p.isTemporary(&pgconn.PgError{Code: "XX001"}))
+	assert.True(t, p.isTemporary(&pgconn.PgError{Code: "0A000"}))
+	assert.True(t, p.isTemporary(&pgconn.PgError{Code: "53300"}))
+	assert.False(t, p.isTemporary(&pgconn.PgError{Code: "53100"}))
+	assert.False(t, p.isTemporary(&pgconn.PgError{Code: "XX000"}))
+	assert.True(t, p.isTemporary(&pgconn.PgError{Code: "40P01"}))
+	assert.True(t, p.isTemporary(staleTableError{&pgconn.PgError{Code: "42P01"}}))
+
+	p = newPostgresql()
+	p.PermanentErrorCodes = []string{"5"}
+	require.Error(t, p.Init())
+}
+
+func TestIsTempError_lockNotAvailable(t *testing.T) {
+	assert.True(t, isTempError(&pgconn.PgError{Code: "55P03"}))
+	assert.False(t, isTempError(&pgconn.PgError{Code: "55000"}))
+}
+
 func TestPostgresqlConnect(t *testing.T) {
 	p := newPostgresqlTest(t)
 	require.NoError(t, p.Connect())
@@ -302,6 +738,135 @@ func TestPostgresqlConnect(t *testing.T) {
 	assert.EqualValues(t, 2, p.db.Stat().MaxConns())
 }
 
+func TestPostgresqlConnect_dataTypes(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.DataTypes = []pgtype.DataType{{Value: &pgtype.Text{}, Name: "text"}}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+
+	conn, err := p.db.Acquire(ctx)
+	require.NoError(t, err)
+	defer conn.Release()
+	dt, ok := conn.Conn().ConnInfo().DataTypeForName("text")
+	require.True(t, ok)
+	assert.EqualValues(t, pgtype.TextOID, dt.OID)
+}
+
+func TestPostgresqlConnect_disableSynchronousCommit(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.DisableSynchronousCommit = true
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+
+	var setting string
+	require.NoError(t, p.db.QueryRow(ctx, "SHOW synchronous_commit").Scan(&setting))
+	assert.Equal(t, "off", setting)
+}
+
+func TestPostgresqlConnect_connectSQL(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.ConnectSQL = []string{"SET work_mem = '17MB'", "SET application_name = 'connect_sql'"}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+
+	var workMem, applicationName string
+	require.NoError(t, p.db.QueryRow(ctx, "SHOW work_mem").Scan(&workMem))
+	require.NoError(t, p.db.QueryRow(ctx, "SHOW application_name").Scan(&applicationName))
+	assert.Equal(t, "17MB", workMem)
+	assert.Equal(t, "connect_sql", applicationName)
+	require.NoError(t, p.Close())
+
+	p = newPostgresqlTest(t)
+	p.ConnectSQL = []string{"SET nonexistent = 1"}
+	require.NoError(t, p.Init())
+	assert.Error(t, p.Connect())
+}
+
+func TestPostgresqlConnect_initSQL(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.InitSQL = []string{
+		"CREATE OR REPLACE FUNCTION " + p.Schema + ".init_sql_test() RETURNS integer LANGUAGE sql AS 'SELECT 42'",
+	}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+
+	var answer int
+	require.NoError(t, p.db.QueryRow(ctx, "SELECT "+p.Schema+".init_sql_test()").Scan(&answer))
+	assert.Equal(t, 42, answer)
+	require.NoError(t, p.Close())
+
+	p = newPostgresqlTest(t)
+	p.InitSQL = []string{"SELECT nonexistent()"}
+	require.NoError(t, p.Init())
+	err := p.Connect()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SELECT nonexistent()")
+}
+
+func TestPostgresqlConnect_createDatabase(t *testing.T) {
+	p := newPostgresqlTest(t)
+	require.NoError(t, p.Connect())
+	_, err := p.db.Exec(ctx, "DROP DATABASE IF EXISTS telegraf_created")
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+
+	p = newPostgresqlTest(t)
+	p.Connection = "database=telegraf_created"
+	require.NoError(t, p.Init())
+	require.Error(t, p.Connect())
+
+	p = newPostgresqlTest(t)
+	p.Connection = "database=telegraf_created"
+	p.CreateDatabase = true
+	p.CreateDatabaseTemplate = "template0"
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", nil, MSI{"v": 1})}))
+	assert.Len(t, dbTableDump(t, p.db, ""), 1)
+	require.NoError(t, p.Close())
+}
+
+func TestPostgresqlConnect_tagCachePreload(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Connect())
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	require.NoError(t, p.Close())
+
+	p = newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	p.TagCachePreload = 10
+	require.NoError(t, p.Connect())
+
+	// The tag is known, so only the metric is written.
+	p.Logger.Clear()
+	require.NoError(t, p.Write(metrics))
+	stmtCount := 0
+	for _, log := range p.Logger.Logs() {
+		if strings.Contains(log.String(), "info: PG ") {
+			stmtCount++
+		}
+	}
+	assert.Equal(t, 3, stmtCount) // BEGIN, COPY metrics table, COMMIT
+}
+
+func TestPostgresqlConnect_createSchema(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.Schema = t.Name()
+	require.NoError(t, p.Connect())
+
+	var exists bool
+	row := p.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname=$1)", t.Name())
+	require.NoError(t, row.Scan(&exists))
+	assert.True(t, exists)
+
+	_, err := p.db.Exec(ctx, "DROP SCHEMA "+utils.QuoteIdentifier(t.Name()))
+	require.NoError(t, err)
+}
+
 func newMetric(
 	t *testing.T,
 	suffix string,
@@ -367,6 +932,32 @@ func TestWrite_sequential(t *testing.T) {
 	assert.Equal(t, 6, stmtCount) // BEGIN, SAVEPOINT, COPY table _a, SAVEPOINT, COPY table _b, COMMIT
 }
 
+func TestWrite_enumTags(t *testing.T) {
+	p := newPostgresqlTest(t)
+	tagKey := t.Name() + "_tag"
+	p.EnumTags = []string{tagKey}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+	defer p.db.Exec(ctx, "DROP TYPE IF EXISTS "+utils.FullTableName(p.Schema, enumTypeName(tagKey)).Sanitize()+" CASCADE") //nolint:errcheck
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{tagKey: "foo"}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	// A value added to the type is usable by the write's transaction.
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{tagKey: "bar"}, MSI{"v": 2}),
+		newMetric(t, "", MSS{tagKey: "baz"}, MSI{"v": 3}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 3)
+	assert.Equal(t, "bar", dump[1][tagKey])
+	assert.Equal(t, "baz", dump[2][tagKey])
+}
+
 func TestWrite_concurrent(t *testing.T) {
 	p := newPostgresqlTest(t)
 	p.dbConfig.MaxConns = 3
@@ -423,6 +1014,191 @@ func TestWrite_concurrent(t *testing.T) {
 }
 
 // Test that the bad metric is dropped, and the rest of the batch succeeds.
+func TestPostgresql_writeChanFor(t *testing.T) {
+	p := newPostgresql()
+	p.writeChans = make([]chan *TableSource, 4)
+	for i := range p.writeChans {
+		p.writeChans[i] = make(chan *TableSource)
+	}
+	assert.Equal(t, p.writeChanFor("foo", 0), p.writeChanFor("foo", 0))
+
+	used := map[chan *TableSource]bool{}
+	for i := 0; i < 100; i++ {
+		used[p.writeChanFor(fmt.Sprintf("table%d", i), 0)] = true
+	}
+	assert.Len(t, used, 4)
+
+	// Partitions are written by the workers following the table's.
+	worker := func(c chan *TableSource) int {
+		for i := range p.writeChans {
+			if p.writeChans[i] == c {
+				return i
+			}
+		}
+		return -1
+	}
+	assert.Equal(t, (worker(p.writeChanFor("table0", 0))+1)%4, worker(p.writeChanFor("table0", 1)))
+	assert.Equal(t, p.writeChanFor("table0", 0), p.writeChanFor("table0", 4))
+}
+
+func TestPostgresql_splitByPartition(t *testing.T) {
+	p := newPostgresql()
+	p.PartitionInterval = config.Duration(time.Hour)
+	require.NoError(t, p.Init())
+
+	ts := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics := []telegraf.Metric{
+		testutil.MustMetric(t.Name(), nil, MSI{"v": 1}, ts.Add(2*time.Hour)),
+		testutil.MustMetric(t.Name(), nil, MSI{"v": 2}, ts),
+		testutil.MustMetric(t.Name(), nil, MSI{"v": 3}, ts.Add(time.Minute)),
+	}
+	tsrc := NewTableSources(p, metrics)[t.Name()]
+
+	parts := p.splitByPartition(tsrc)
+	require.Len(t, parts, 2)
+	assert.Equal(t, metrics[1:], parts[0].metrics)
+	assert.Equal(t, metrics[:1], parts[1].metrics)
+
+	parts = p.splitByPartition(NewTableSources(p, metrics[1:])[t.Name()])
+	require.Len(t, parts, 1)
+}
+
+func TestWrite_writeQueueTimeout(t *testing.T) {
+	p := newPostgresql()
+	p.WriteQueueTimeout = config.Duration(10 * time.Millisecond)
+	p.dbContext = context.Background()
+	// No worker reads the channel, so the sub-batch can't be queued.
+	p.writeChans = []chan *TableSource{make(chan *TableSource)}
+
+	metrics := []telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 1})}
+	tableSources := map[string]*TableSource{"foo": {metrics: metrics}}
+	unqueued, err := p.writeConcurrent(tableSources)
+	require.Error(t, err)
+	assert.True(t, isTempError(err))
+	assert.Equal(t, metrics, unqueued)
+}
+
+func TestWrite_writeConcurrentClosing(t *testing.T) {
+	p := newPostgresql()
+	var cancel context.CancelFunc
+	p.dbContext, cancel = context.WithCancel(context.Background())
+	cancel()
+	// No worker reads the channel, so the sub-batch can't be queued.
+	p.writeChans = []chan *TableSource{make(chan *TableSource)}
+
+	metrics := []telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 1})}
+	tableSources := map[string]*TableSource{"foo": {metrics: metrics}}
+	unqueued, err := p.writeConcurrent(tableSources)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, metrics, unqueued)
+}
+
+func TestWrite_writeQueueTimeout_queuedOnce(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.dbConfig.MaxConns = 2
+	p.MaxRowsPerCopy = 1
+	p.WriteQueueTimeout = config.Duration(100 * time.Millisecond)
+	require.NoError(t, p.Connect())
+	// Written by different workers.
+	require.NotEqual(t, p.writeChanFor(t.Name()+"_a", 0), p.writeChanFor(t.Name()+"_b", 0))
+
+	countRows := func(suffix string) int {
+		var n int
+		row := p.db.QueryRow(ctx, "SELECT count(*) FROM "+pgx.Identifier{t.Name() + suffix}.Sanitize())
+		if err := row.Scan(&n); err != nil {
+			return -1
+		}
+		return n
+	}
+
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "_a", MSS{}, MSI{"v": 1})}))
+	require.Eventually(t, func() bool { return countRows("_a") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Lock the table so that its worker hangs writing the next sub-batch, and can't accept another.
+	tx, err := p.db.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx) //nolint:errcheck
+	_, err = tx.Exec(ctx, "LOCK TABLE "+utils.QuoteIdentifier(t.Name()+"_a"))
+	require.NoError(t, err)
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "_a", MSS{}, MSI{"v": 2})}))
+
+	// The sub-batch of _b is queued, those of _a time out, and are kept for the next write rather than telegraf
+	// retrying the whole batch.
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric(t, "_b", MSS{}, MSI{"v": 10}),
+		newMetric(t, "_a", MSS{}, MSI{"v": 3}),
+		newMetric(t, "_a", MSS{}, MSI{"v": 4}),
+	}))
+	require.Eventually(t, func() bool { return countRows("_b") == 1 }, 5*time.Second, 10*time.Millisecond)
+	// Still timing out, so the next write is left for telegraf to retry.
+	require.Error(t, p.Write([]telegraf.Metric{newMetric(t, "_b", MSS{}, MSI{"v": 11})}))
+
+	_ = tx.Rollback(ctx)
+	require.Eventually(t, func() bool { return p.Write(nil) == nil }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return countRows("_a") == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	var values []interface{}
+	for _, row := range dbTableDump(t, p.db, "_a") {
+		values = append(values, row["v"])
+	}
+	assert.ElementsMatch(t, []interface{}{int64(1), int64(2), int64(3), int64(4)}, values)
+	assert.Len(t, dbTableDump(t, p.db, "_b"), 1)
+}
+
+func TestWriteTimings(t *testing.T) {
+	logger := NewLogAccumulator(t)
+	timings := newWriteTimings(t.Name())
+	time.Sleep(time.Millisecond)
+	timings.lap(&timings.matchSource)
+	timings.lap(&timings.copy)
+	timings.record(logger)
+
+	fields := map[string]interface{}{}
+	for _, m := range selfstat.Metrics() {
+		if m.Name() == "internal_postgresql" && m.Tags()["table"] == t.Name() {
+			fields = m.Fields()
+		}
+	}
+	assert.Contains(t, fields, "match_source_time_ns")
+	assert.Contains(t, fields, "copy_time_ns")
+	assert.NotContains(t, fields, "tag_write_time_ns")
+	assert.GreaterOrEqual(t, fields["match_source_time_ns"], int64(time.Millisecond))
+
+	require.Len(t, logger.Logs(), 1)
+	assert.Contains(t, logger.Logs()[0].String(), "match_source=")
+}
+
+func TestWriteLimiter(t *testing.T) {
+	l := newWriteLimiter(3, time.Second)
+	require.True(t, l.acquire(ctx))
+
+	// Not raised without other workers waiting.
+	l.release(time.Millisecond)
+	assert.Equal(t, 1, l.currentLimit())
+
+	require.True(t, l.acquire(ctx))
+	waiting := make(chan bool)
+	go func() { waiting <- l.acquire(ctx) }()
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.waiting == 1
+	}, time.Second, time.Millisecond)
+	l.release(time.Millisecond)
+	assert.Equal(t, 2, l.currentLimit())
+	assert.True(t, <-waiting)
+
+	// Lowered when the target latency is exceeded. The token is discarded rather than returned.
+	l.release(2 * time.Second)
+	assert.Equal(t, 1, l.currentLimit())
+	assert.Len(t, l.tokens, 1)
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	require.True(t, l.acquire(cancelCtx))
+	cancel()
+	assert.False(t, l.acquire(cancelCtx))
+}
+
 func TestWrite_sequentialPermError(t *testing.T) {
 	p := newPostgresqlTest(t)
 	require.NoError(t, p.Connect())
@@ -455,63 +1231,289 @@ func TestWrite_sequentialPermError(t *testing.T) {
 }
 
 // Test that the bad metric is dropped, and the rest of the batch succeeds.
-func TestWrite_concurrentPermError(t *testing.T) {
+func TestWrite_deadLetterTable(t *testing.T) {
 	p := newPostgresqlTest(t)
-	p.dbConfig.MaxConns = 2
+	p.DeadLetterTable = t.Name() + "_dead"
 	require.NoError(t, p.Connect())
 
 	metrics := []telegraf.Metric{
 		newMetric(t, "_a", MSS{}, MSI{"v": 1}),
+		newMetric(t, "_b", MSS{}, MSI{"v": 2}),
 	}
 	require.NoError(t, p.Write(metrics))
-	p.Logger.WaitForCopy(t.Name()+"_a", false)
 
 	metrics = []telegraf.Metric{
-		newMetric(t, "_a", MSS{}, MSI{"v": "a"}),
-		newMetric(t, "_b", MSS{}, MSI{"v": 2}),
+		newMetric(t, "_a", MSS{"tag": "foo"}, MSI{"v": "a"}),
+		newMetric(t, "_b", MSS{}, MSI{"v": 3}),
 	}
 	require.NoError(t, p.Write(metrics))
-	p.Logger.WaitFor(func(l Log) bool {
-		return strings.Contains(l.String(), "write error")
-	}, false)
-	p.Logger.WaitForCopy(t.Name()+"_b", false)
+	assert.Len(t, dbTableDump(t, p.db, "_b"), 2)
 
-	dumpA := dbTableDump(t, p.db, "_a")
-	dumpB := dbTableDump(t, p.db, "_b")
-	assert.Len(t, dumpA, 1)
-	assert.Len(t, dumpB, 1)
+	dump := dbTableDump(t, p.db, "_dead")
+	require.Len(t, dump, 1)
+	assert.Equal(t, t.Name()+"_a", dump[0]["measurement"])
+	assert.NotEmpty(t, dump[0]["error"])
+	assert.Equal(t, map[string]interface{}{
+		"name":      t.Name() + "_a",
+		"tags":      map[string]interface{}{"tag": "foo"},
+		"fields":    map[string]interface{}{"v": "a"},
+		"timestamp": dump[0]["metric"].(map[string]interface{})["timestamp"],
+	}, dump[0]["metric"])
 }
 
-// Verify that in sequential mode, errors are returned allowing telegraf agent to handle & retry
-func TestWrite_sequentialTempError(t *testing.T) {
+func TestPostgresql_deadLetterFile(t *testing.T) {
+	p := newPostgresql()
+	p.Logger = NewLogAccumulator(t)
+	p.DeadLetterFile = filepath.Join(t.TempDir(), "dead_letters")
+	require.NoError(t, p.Init())
+
+	m := newMetric(t, "", MSS{"tag": "foo"}, MSI{"v": uint64(1)})
+	m.SetTime(time.Unix(0, 1))
+	p.deadLetter(ctx, nil, []telegraf.Metric{m}, fmt.Errorf("permanent"))
+	p.deadLetter(ctx, nil, []telegraf.Metric{m}, fmt.Errorf("permanent"))
+
+	data, err := os.ReadFile(p.DeadLetterFile)
+	require.NoError(t, err)
+	line := t.Name() + ",tag=foo v=1u 1\n"
+	assert.Equal(t, line+line, string(data))
+}
+
+func TestWrite_salvageRows(t *testing.T) {
 	p := newPostgresqlTest(t)
+	p.SalvageRows = true
+	p.DeadLetterTable = t.Name() + "_dead"
 	require.NoError(t, p.Connect())
 
-	// To avoid a race condition, we need to know when our goroutine has started listening to the log.
-	wg := sync.WaitGroup{}
-	wg.Add(1)
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 1})}))
 
-	go func() {
-		// Wait for the CREATE TABLE, and then kill the connection.
-		// The WaitFor callback holds a lock on the log. Meaning it will block logging of the next action. So we trigger
-		// on CREATE TABLE so that there's a few statements to go before the COMMIT.
-		p.Logger.WaitFor(func(log Log) bool {
-			if strings.Contains(log.String(), "release wg") {
-				wg.Done()
-			}
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 2}),
+		newMetric(t, "", MSS{}, MSI{"v": "a"}),
+		newMetric(t, "", MSS{}, MSI{"v": 3}),
+		newMetric(t, "", MSS{}, MSI{"v": 4}),
+	}
+	require.NoError(t, p.Write(metrics))
 
-			if !strings.Contains(log.String(), "CREATE TABLE") {
-				return false
-			}
-			pid := log.args[1].(MSI)["pid"].(uint32)
+	// Only the metric with the string value is dropped.
+	assert.Len(t, dbTableDump(t, p.db, ""), 4)
+	dump := dbTableDump(t, p.db, "_dead")
+	require.Len(t, dump, 1)
+	assert.EqualValues(t, "a", dump[0]["metric"].(map[string]interface{})["fields"].(map[string]interface{})["v"])
+}
 
-			conf := p.db.Config().ConnConfig
-			conf.Logger = nil
-			c, err := pgx.ConnectConfig(context.Background(), conf)
-			if !assert.NoError(t, err) {
-				return true
-			}
-			_, err = c.Exec(context.Background(), "SELECT pg_terminate_backend($1)", pid)
+func TestWrite_salvageRowsConnectionLost(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.dbConfig.MaxConns = 2
+	p.SalvageRows = true
+	p.DeadLetterTable = t.Name() + "_dead"
+	require.NoError(t, p.Connect())
+
+	countRows := func(suffix string) int {
+		var n int
+		row := p.db.QueryRow(ctx, "SELECT count(*) FROM "+pgx.Identifier{t.Name() + suffix}.Sanitize())
+		if err := row.Scan(&n); err != nil {
+			return -1
+		}
+		return n
+	}
+
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 1})}))
+	require.Eventually(t, func() bool { return countRows("") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The value 99 fails the copy permanently, so it is salvaged, and the connection is killed the first time the value
+	// 3 is copied, after the half before it was copied.
+	table := pgx.Identifier{t.Name()}.Sanitize()
+	seqName := pgx.Identifier{t.Name() + "_seq"}.Sanitize()
+	fnName := pgx.Identifier{t.Name() + "_fn"}.Sanitize()
+	_, err := p.db.Exec(ctx, "ALTER TABLE "+table+" ADD CHECK (v <> 99)")
+	require.NoError(t, err)
+	_, err = p.db.Exec(ctx, "CREATE SEQUENCE "+seqName)
+	require.NoError(t, err)
+	_, err = p.db.Exec(ctx, "CREATE FUNCTION "+fnName+"() RETURNS trigger LANGUAGE plpgsql AS "+
+		"$$BEGIN IF NEW.v = 3 AND nextval('"+seqName+"') = 1 THEN PERFORM pg_terminate_backend(pg_backend_pid()); "+
+		"END IF; RETURN NEW; END$$")
+	require.NoError(t, err)
+	_, err = p.db.Exec(ctx, "CREATE TRIGGER "+pgx.Identifier{t.Name() + "_trg"}.Sanitize()+
+		" BEFORE INSERT ON "+table+" FOR EACH ROW EXECUTE PROCEDURE "+fnName+"()")
+	require.NoError(t, err)
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 2}),
+		newMetric(t, "", MSS{}, MSI{"v": 99}),
+		newMetric(t, "", MSS{}, MSI{"v": 3}),
+		newMetric(t, "", MSS{}, MSI{"v": 4}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	// The metric is dropped once the rest are committed.
+	require.Eventually(t, func() bool { return countRows("_dead") > 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, countRows("_dead"))
+	var values []int64
+	for _, row := range dbTableDump(t, p.db, "") {
+		values = append(values, row["v"].(int64))
+	}
+	assert.ElementsMatch(t, []int64{1, 2, 3, 4}, values)
+}
+
+func TestPostgresql_retryAsyncFailures(t *testing.T) {
+	p := newPostgresql()
+	p.Logger = NewLogAccumulator(t)
+	require.NoError(t, p.Init())
+	p.dbContext = ctx
+	p.writeChans = []chan *TableSource{make(chan *TableSource, 1)}
+
+	require.NoError(t, p.retryAsyncFailures())
+
+	failed := []telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 1})}
+	p.recordAsyncFailure(failed, fmt.Errorf("connection reset"))
+
+	// The error is returned once, and the failed metrics are queued again.
+	err := p.writeMetrics([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 2})})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+	require.Len(t, p.writeChans[0], 1)
+	assert.Equal(t, failed, (<-p.writeChans[0]).metrics)
+	require.NoError(t, p.retryAsyncFailures())
+}
+
+func TestWrite_ddlDryRun(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.DDLDryRun = true
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"v": 1}),
+		newMetric(t, "", MSS{"tag": "bar"}, MSI{"v": 2}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	logs := ""
+	for _, l := range p.Logger.Logs() {
+		logs += l.String() + "\n"
+	}
+	table := utils.FullTableName(p.Schema, t.Name()).Sanitize()
+	tagTable := utils.FullTableName(p.Schema, t.Name()+p.TagTableSuffix).Sanitize()
+	assert.Contains(t, logs, "Write (dry run): 2 rows to "+table+" (time, tag_id, v), 0 rows dropped\n")
+	assert.Contains(t, logs, "Write (dry run): 2 rows to "+tagTable+"\n")
+	assert.Empty(t, dbTableDump(t, p.db, ""))
+}
+
+func TestWrite_spill(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.SpillDirectory = t.TempDir()
+	require.NoError(t, p.Connect())
+
+	// As if spilled while the database was unreachable.
+	require.NoError(t, p.spill([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 1})}, fmt.Errorf("unreachable")))
+
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 2})}))
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 2)
+	assert.EqualValues(t, 1, dump[0]["v"])
+	assert.EqualValues(t, 2, dump[1]["v"])
+	assert.Empty(t, p.spillSegments)
+	assert.EqualValues(t, 0, p.spillSize)
+}
+
+func TestWrite_sequentialPermError_noSavepoints(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.Savepoints = "never"
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "_a", MSS{}, MSI{"v": 1}),
+		newMetric(t, "_b", MSS{}, MSI{"v": 2}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	// The whole batch is dropped.
+	metrics = []telegraf.Metric{
+		newMetric(t, "_a", MSS{}, MSI{"v": "a"}),
+		newMetric(t, "_b", MSS{}, MSI{"v": 3}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	assert.Len(t, dbTableDump(t, p.db, "_a"), 1)
+	assert.Len(t, dbTableDump(t, p.db, "_b"), 1)
+}
+
+func TestPostgresql_useSavepoints(t *testing.T) {
+	p := newPostgresql()
+	require.NoError(t, p.Init())
+	assert.False(t, p.useSavepoints(1))
+	assert.True(t, p.useSavepoints(2))
+
+	p.SavepointMinTables = 3
+	assert.False(t, p.useSavepoints(2))
+	p.Savepoints = "always"
+	assert.True(t, p.useSavepoints(1))
+	p.Savepoints = "never"
+	assert.False(t, p.useSavepoints(5))
+
+	p = newPostgresql()
+	p.Savepoints = "sometimes"
+	require.Error(t, p.Init())
+}
+
+func TestWrite_concurrentPermError(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.dbConfig.MaxConns = 2
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "_a", MSS{}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	p.Logger.WaitForCopy(t.Name()+"_a", false)
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "_a", MSS{}, MSI{"v": "a"}),
+		newMetric(t, "_b", MSS{}, MSI{"v": 2}),
+	}
+	require.NoError(t, p.Write(metrics))
+	p.Logger.WaitFor(func(l Log) bool {
+		return strings.Contains(l.String(), "write error")
+	}, false)
+	p.Logger.WaitForCopy(t.Name()+"_b", false)
+
+	dumpA := dbTableDump(t, p.db, "_a")
+	dumpB := dbTableDump(t, p.db, "_b")
+	assert.Len(t, dumpA, 1)
+	assert.Len(t, dumpB, 1)
+}
+
+// Verify that in sequential mode, errors are returned allowing telegraf agent to handle & retry
+func TestWrite_sequentialTempError(t *testing.T) {
+	p := newPostgresqlTest(t)
+	require.NoError(t, p.Connect())
+
+	// To avoid a race condition, we need to know when our goroutine has started listening to the log.
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		// Wait for the CREATE TABLE, and then kill the connection.
+		// The WaitFor callback holds a lock on the log. Meaning it will block logging of the next action. So we trigger
+		// on CREATE TABLE so that there's a few statements to go before the COMMIT.
+		p.Logger.WaitFor(func(log Log) bool {
+			if strings.Contains(log.String(), "release wg") {
+				wg.Done()
+			}
+
+			if !strings.Contains(log.String(), "CREATE TABLE") {
+				return false
+			}
+			pid := log.args[1].(MSI)["pid"].(uint32)
+
+			conf := p.db.Config().ConnConfig
+			conf.Logger = nil
+			c, err := pgx.ConnectConfig(context.Background(), conf)
+			if !assert.NoError(t, err) {
+				return true
+			}
+			_, err = c.Exec(context.Background(), "SELECT pg_terminate_backend($1)", pid)
 			assert.NoError(t, err)
 			return true
 		}, false)
@@ -614,6 +1616,117 @@ func TestWriteTagTable(t *testing.T) {
 	assert.Equal(t, 3, stmtCount) // BEGIN, COPY metrics table, COMMIT
 }
 
+func TestWriteTagTable_tagIDHash128(t *testing.T) {
+	for _, typ := range []string{PgUUID, PgNumeric} {
+		t.Run(typ, func(t *testing.T) {
+			p := newPostgresqlTest(t)
+			p.TagsAsForeignKeys = true
+			p.TagIDHash = "fnv128a"
+			p.TagIDType = typ
+			require.NoError(t, p.Init())
+			require.NoError(t, p.Connect())
+
+			metrics := []telegraf.Metric{
+				newMetric(t, "", MSS{"tag": "foo"}, MSI{"v": 1}),
+				newMetric(t, "", MSS{"tag": "bar"}, MSI{"v": 2}),
+			}
+			require.NoError(t, p.Write(metrics))
+			// The tags are found in the cache.
+			require.NoError(t, p.Write(metrics))
+
+			dump := dbTableDump(t, p.db, "")
+			require.Len(t, dump, 4)
+			dumpTags := dbTableDump(t, p.db, p.TagTableSuffix)
+			require.Len(t, dumpTags, 2)
+			assert.Equal(t, typ, p.tableManager.table(t.Name()).columns[tagIDColumnName].Type)
+		})
+	}
+}
+
+func TestWriteTagTables(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "_a", MSS{"tag": "foo"}, MSI{"v": 1}),
+		newMetric(t, "_b", MSS{"tag": "foo"}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	// The tag tables are now known, so the new tags of both are written together.
+	p.Logger.Clear()
+	metrics = []telegraf.Metric{
+		newMetric(t, "_a", MSS{"tag": "bar"}, MSI{"v": 2}),
+		newMetric(t, "_b", MSS{"tag": "baz"}, MSI{"v": 2}),
+	}
+	require.NoError(t, p.Write(metrics))
+	for _, l := range p.Logger.Logs() {
+		assert.NotContains(t, l.String(), "CREATE TEMP TABLE")
+	}
+
+	assert.Len(t, dbTableDump(t, p.db, "_a"+p.TagTableSuffix), 2)
+	assert.Len(t, dbTableDump(t, p.db, "_b"+p.TagTableSuffix), 2)
+	assert.Len(t, dbTableDump(t, p.db, "_a"), 2)
+}
+
+// Verify that a handful of new tags are inserted directly, and many through a temp table.
+func TestWriteTagTable_directInsert(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	for _, l := range p.Logger.Logs() {
+		assert.NotContains(t, l.String(), "CREATE TEMP TABLE")
+	}
+
+	p.Logger.Clear()
+	metrics = nil
+	for i := 0; i <= directTagInsertMaxRows; i++ {
+		metrics = append(metrics, newMetric(t, "", MSS{"tag": strconv.Itoa(i)}, MSI{"v": i}))
+	}
+	require.NoError(t, p.Write(metrics))
+	createdTemp := false
+	for _, l := range p.Logger.Logs() {
+		createdTemp = createdTemp || strings.Contains(l.String(), "CREATE TEMP TABLE")
+	}
+	assert.True(t, createdTemp)
+
+	assert.Len(t, dbTableDump(t, p.db, p.TagTableSuffix), directTagInsertMaxRows+2)
+}
+
+func TestPruneTags(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	p.TagPruneRetention = config.Duration(time.Hour)
+	require.NoError(t, p.Connect())
+
+	old := newMetric(t, "", MSS{"tag": "old"}, MSI{"v": 1})
+	old.SetTime(time.Now().Add(-2 * time.Hour))
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"v": 1}),
+		newMetric(t, "", MSS{"tag": "bar"}, MSI{"v": 1}),
+		old,
+	}
+	require.NoError(t, p.Write(metrics))
+	_, err := p.db.Exec(ctx, "DELETE FROM "+utils.FullTableName(p.Schema, t.Name()).Sanitize()+" WHERE tag_id = $1",
+		utils.GetTagID(metrics[1]))
+	require.NoError(t, err)
+
+	require.NoError(t, p.pruneTags())
+	dump := dbTableDump(t, p.db, p.TagTableSuffix)
+	require.Len(t, dump, 1)
+	assert.Equal(t, "foo", dump[0]["tag"])
+
+	// The pruned tags are no longer cached, so are inserted again.
+	require.NoError(t, p.Write(metrics[1:2]))
+	assert.Len(t, dbTableDump(t, p.db, p.TagTableSuffix), 2)
+}
+
 // Verify that when using TagsAsForeignKeys and a tag can't be written, that we still add the metrics.
 func TestWrite_tagError(t *testing.T) {
 	p := newPostgresqlTest(t)
@@ -674,6 +1787,406 @@ func TestWrite_tagError_foreignConstraint(t *testing.T) {
 	assert.EqualValues(t, 1, dump[0]["v"])
 }
 
+func TestWrite_upsert(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.Upsert = true
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(`CREATE TABLE {{.table}} ({{.columns}}, PRIMARY KEY ("time", "tag"))`)))
+	p.CreateTemplates = []*sqltemplate.Template{tmpl}
+	require.NoError(t, p.Connect())
+
+	ts := time.Now().Truncate(time.Second)
+	metrics := []telegraf.Metric{
+		testutil.MustMetric(t.Name(), MSS{"tag": "foo"}, MSI{"v": 1}, ts),
+		testutil.MustMetric(t.Name(), MSS{"tag": "bar"}, MSI{"v": 2}, ts),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	// Of duplicates within the batch, the last is written.
+	metrics = []telegraf.Metric{
+		testutil.MustMetric(t.Name(), MSS{"tag": "foo"}, MSI{"v": 3}, ts),
+		testutil.MustMetric(t.Name(), MSS{"tag": "bar"}, MSI{"v": 4}, ts),
+		testutil.MustMetric(t.Name(), MSS{"tag": "bar"}, MSI{"v": 5}, ts),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 2)
+	values := map[string]interface{}{}
+	for _, row := range dump {
+		values[row["tag"].(string)] = row["v"]
+	}
+	assert.EqualValues(t, 3, values["foo"])
+	assert.EqualValues(t, 5, values["bar"])
+}
+
+func TestWrite_widenColumn(t *testing.T) {
+	p := newPostgresqlTest(t)
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(
+		`ALTER TABLE {{.table}} {{range $i, $c := .columns}}{{if $i}}, {{end}}ALTER COLUMN {{$c.Identifier}} TYPE {{$c.Type}}{{end}}`,
+	)))
+	p.WidenColumnTemplates = []*sqltemplate.Template{tmpl}
+	require.NoError(t, p.Connect())
+
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 1})}))
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 2.5})}))
+	// values of the narrower type are converted to the widened column's type
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": 3})}))
+	assert.Equal(t, PgDoublePrecision, p.tableManager.table(t.Name()).columns["v"].Type)
+
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{}, MSI{"v": "four"})}))
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 5}),
+		newMetric(t, "", MSS{}, MSI{"v": 6.5}),
+	}))
+	assert.Equal(t, PgText, p.tableManager.table(t.Name()).columns["v"].Type)
+
+	dump := dbTableDump(t, p.db, "")
+	var values []interface{}
+	for _, row := range dump {
+		values = append(values, row["v"])
+	}
+	assert.ElementsMatch(t, []interface{}{"1", "2.5", "3", "four", "5", "6.5"}, values)
+}
+
+func TestWrite_noCopy(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.UseCopy = false
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"v": 1}),
+		newMetric(t, "", MSS{"tag": "bar"}, MSI{"v": 2}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 2)
+	tagDump := dbTableDump(t, p.db, p.TagTableSuffix)
+	require.Len(t, tagDump, 2)
+}
+
+func TestWrite_maxRowsPerCopy(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.MaxRowsPerCopy = 2
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"v": 1}),
+		newMetric(t, "", nil, MSI{"v": 2}),
+		newMetric(t, "", nil, MSI{"v": 3}),
+		newMetric(t, "_b", nil, MSI{"v": 4}),
+		newMetric(t, "", nil, MSI{"v": 5}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	assert.Len(t, dbTableDump(t, p.db, ""), 4)
+	assert.Len(t, dbTableDump(t, p.db, "_b"), 1)
+}
+
+func TestWrite_maxRowsPerCopy_tempError(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.MaxRowsPerCopy = 2
+	p.TemporaryErrorCodes = []string{"23514"} // check_violation
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+
+	tableName := pgx.Identifier{t.Name()}.Sanitize()
+	_, err := p.db.Exec(ctx, "CREATE TABLE "+tableName+" (time timestamp, v bigint CHECK (v < 3))")
+	require.NoError(t, err)
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"v": 1}),
+		newMetric(t, "", nil, MSI{"v": 2}),
+		newMetric(t, "", nil, MSI{"v": 3}),
+		newMetric(t, "", nil, MSI{"v": 4}),
+	}
+	// The first part is committed, so the second is kept for the next write rather than telegraf retrying both.
+	require.NoError(t, p.Write(metrics))
+	assert.Len(t, dbTableDump(t, p.db, ""), 2)
+
+	// Still failing, the metrics of the next write are left for telegraf to retry.
+	require.Error(t, p.Write([]telegraf.Metric{newMetric(t, "", nil, MSI{"v": 0})}))
+
+	_, err = p.db.Exec(ctx, "ALTER TABLE "+tableName+" DROP CONSTRAINT "+pgx.Identifier{t.Name() + "_v_check"}.Sanitize())
+	require.NoError(t, err)
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", nil, MSI{"v": 5})}))
+
+	var values []interface{}
+	for _, row := range dbTableDump(t, p.db, "") {
+		values = append(values, row["v"])
+	}
+	assert.ElementsMatch(t, []interface{}{int64(1), int64(2), int64(3), int64(4), int64(5)}, values)
+}
+
+func TestWrite_coalesce(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.CoalesceSize = 3
+	require.NoError(t, p.Connect())
+
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric(t, "", nil, MSI{"v": 1}),
+		newMetric(t, "", nil, MSI{"v": 2}),
+	}))
+	assert.Len(t, p.coalesced, 2)
+	_, err := p.db.Exec(ctx, "SELECT 1 FROM "+pgx.Identifier{t.Name()}.Sanitize())
+	require.Error(t, err, "table should not have been created yet")
+
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric(t, "", nil, MSI{"v": 3}),
+	}))
+	assert.Empty(t, p.coalesced)
+	assert.Len(t, dbTableDump(t, p.db, ""), 3)
+}
+
+func TestWrite_simpleProtocol(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.SimpleProtocol = true
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Init())
+	assert.True(t, p.dbConfig.ConnConfig.PreferSimpleProtocol)
+	assert.Nil(t, p.dbConfig.ConnConfig.BuildStatementCache)
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	require.NoError(t, p.Write(metrics))
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 2)
+}
+
+func TestPostgresql_pgbouncerCompatible(t *testing.T) {
+	p := newPostgresql()
+	p.PgBouncerCompatible = true
+	require.NoError(t, p.Init())
+	assert.True(t, p.dbConfig.ConnConfig.PreferSimpleProtocol)
+	assert.Nil(t, p.dbConfig.ConnConfig.BuildStatementCache)
+	assert.NotContains(t, p.dbConfig.ConnConfig.RuntimeParams, "synchronous_commit")
+
+	p = newPostgresql()
+	p.PgBouncerCompatible = true
+	p.DisableSynchronousCommit = true
+	assert.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.PgBouncerCompatible = true
+	p.Tablespace = "fast"
+	assert.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.PgBouncerCompatible = true
+	p.ConnectSQL = []string{"SET ROLE metrics_writer"}
+	assert.Error(t, p.Init())
+}
+
+func TestWrite_pgbouncerCompatible(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.PgBouncerCompatible = true
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Connect())
+
+	// More new tags than are otherwise inserted without a temp table.
+	var metrics []telegraf.Metric
+	for i := 0; i < directTagInsertMaxRows*2; i++ {
+		metrics = append(metrics, newMetric(t, "", MSS{"tag": strconv.Itoa(i)}, MSI{"v": i}))
+	}
+	require.NoError(t, p.Write(metrics))
+
+	assert.Len(t, dbTableDump(t, p.db, ""), len(metrics))
+	assert.Len(t, dbTableDump(t, p.db, p.TagTableSuffix), len(metrics))
+}
+
+func TestWrite_ddlLockTimeout(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.DDLLockTimeout = config.Duration(100 * time.Millisecond)
+	require.NoError(t, p.Connect())
+
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", nil, MSI{"a": 1})}))
+
+	// Hold a lock on the table, which blocks the ALTER TABLE adding the new column.
+	tx, err := p.db.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx) //nolint:errcheck
+	_, err = tx.Exec(ctx, "SELECT * FROM "+pgx.Identifier{t.Name()}.Sanitize())
+	require.NoError(t, err)
+
+	err = p.Write([]telegraf.Metric{newMetric(t, "", nil, MSI{"a": 2, "b": 2})})
+	require.Error(t, err)
+	assert.True(t, isTempError(err))
+
+	require.NoError(t, tx.Rollback(ctx))
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", nil, MSI{"a": 2, "b": 2})}))
+}
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), jitter(0))
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second)
+		assert.GreaterOrEqual(t, d, 500*time.Millisecond)
+		assert.LessOrEqual(t, d, time.Second)
+	}
+}
+
+func TestWriteRetry_maxAttempts(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.dbConfig.MaxConns = 2
+	p.DDLLockTimeout = config.Duration(100 * time.Millisecond)
+	p.RetryMaxAttempts = 2
+	require.NoError(t, p.Connect())
+
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", nil, MSI{"a": 1})}))
+	p.Logger.WaitForCopy(t.Name(), false)
+
+	// Hold a lock on the table, so that adding the new column fails with a temporary error every time.
+	tx, err := p.db.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx) //nolint:errcheck
+	_, err = tx.Exec(ctx, "SELECT * FROM "+pgx.Identifier{t.Name()}.Sanitize())
+	require.NoError(t, err)
+
+	tableSource := NewTableSources(p.Postgresql, []telegraf.Metric{newMetric(t, "", nil, MSI{"a": 2, "b": 2})})[t.Name()]
+	err = p.writeRetry(ctx, tableSource)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "giving up after 2 attempts")
+	assert.True(t, isTempError(err))
+}
+
+func TestWrite_ddlPool(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.DDLPoolMaxConns = 1
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Connect())
+	defer p.ddlDB.Close()
+
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1})}))
+	require.NoError(t, p.Write([]telegraf.Metric{newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 2, "b": 2})}))
+
+	assert.EqualValues(t, 1, p.ddlDB.Stat().TotalConns())
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 2)
+	assert.Contains(t, dump[1], "b")
+}
+
+func TestWrite_ignoreDuplicates(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.IgnoreDuplicates = true
+	p.TagsAsForeignKeys = true
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(`CREATE TABLE {{.table}} ({{.columns}}, UNIQUE ("time", "tag_id"))`)))
+	p.CreateTemplates = []*sqltemplate.Template{tmpl}
+	require.NoError(t, p.Connect())
+
+	ts := time.Now().Truncate(time.Second)
+	metrics := []telegraf.Metric{
+		testutil.MustMetric(t.Name(), MSS{"tag": "foo"}, MSI{"v": 1}, ts),
+		testutil.MustMetric(t.Name(), MSS{"tag": "foo"}, MSI{"v": 2}, ts),
+	}
+	require.NoError(t, p.Write(metrics))
+	require.NoError(t, p.Write(metrics))
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 1)
+	assert.EqualValues(t, 1, dump[0]["v"])
+}
+
+func TestWrite_concurrentDroppedTable(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.dbConfig.MaxConns = 2
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	p.Logger.WaitForCopy(t.Name(), false)
+	p.Logger.Clear()
+
+	_, err := p.db.Exec(ctx, "DROP TABLE "+utils.QuoteIdentifier(t.Name()))
+	require.NoError(t, err)
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 2}),
+	}
+	require.NoError(t, p.Write(metrics))
+	// The first attempt fails on the missing table, so wait for the retry to succeed.
+	p.Logger.WaitFor(func(log Log) bool {
+		return log.format == "PG %s - %+v" &&
+			log.level != pgx.LogLevelError &&
+			log.args[0].(string) == "CopyFrom" &&
+			log.args[1].(MSI)["tableName"].(pgx.Identifier)[1] == t.Name()
+	}, false)
+
+	dump := dbTableDump(t, p.db, "")
+	if assert.Len(t, dump, 1) {
+		assert.EqualValues(t, 2, dump[0]["v"])
+	}
+}
+
+func TestWrite_concurrentDroppedColumn(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.dbConfig.MaxConns = 2
+	p.AddColumnTemplates = []*sqltemplate.Template{}
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 1, "w": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	p.Logger.WaitForCopy(t.Name(), false)
+	p.Logger.Clear()
+
+	_, err := p.db.Exec(ctx, "ALTER TABLE "+utils.QuoteIdentifier(t.Name())+" DROP COLUMN w")
+	require.NoError(t, err)
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 2, "w": 2}),
+	}
+	require.NoError(t, p.Write(metrics))
+	// The first attempt fails on the missing column, so wait for the retry to succeed.
+	p.Logger.WaitFor(func(log Log) bool {
+		return log.format == "PG %s - %+v" &&
+			log.level != pgx.LogLevelError &&
+			log.args[0].(string) == "CopyFrom" &&
+			log.args[1].(MSI)["tableName"].(pgx.Identifier)[1] == t.Name()
+	}, false)
+
+	dump := dbTableDump(t, p.db, "")
+	if assert.Len(t, dump, 2) {
+		assert.EqualValues(t, 2, dump[1]["v"])
+		assert.NotContains(t, dump[1], "w")
+	}
+}
+
+func TestWrite_sequentialStaleTablePersistent(t *testing.T) {
+	p := newPostgresqlTest(t)
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	// A trigger referencing a table which doesn't exist fails every write with undefined_table.
+	fnName := pgx.Identifier{t.Name() + "_fn"}.Sanitize()
+	_, err := p.db.Exec(ctx, "CREATE FUNCTION "+fnName+"() RETURNS trigger LANGUAGE plpgsql AS "+
+		"$$BEGIN INSERT INTO "+pgx.Identifier{t.Name() + "_missing"}.Sanitize()+" VALUES (1); RETURN NEW; END$$")
+	require.NoError(t, err)
+	_, err = p.db.Exec(ctx, "CREATE TRIGGER "+pgx.Identifier{t.Name() + "_trg"}.Sanitize()+
+		" BEFORE INSERT ON "+pgx.Identifier{t.Name()}.Sanitize()+" FOR EACH ROW EXECUTE PROCEDURE "+fnName+"()")
+	require.NoError(t, err)
+
+	// Retried once, in case the cached table structure is out of date.
+	require.Error(t, p.Write(metrics))
+	// The retry fails the same way, so the metrics are dropped, rather than the batch being retried forever.
+	require.NoError(t, p.Write(metrics))
+	assert.Len(t, dbTableDump(t, p.db, ""), 1)
+}
+
 func TestWrite_UnsignedIntegers(t *testing.T) {
 	p := newPostgresqlTest(t)
 	p.UseUint8 = true
@@ -700,6 +2213,43 @@ func TestWrite_UnsignedIntegers(t *testing.T) {
 	}
 }
 
+func TestWrite_geometryPoints(t *testing.T) {
+	p := newPostgresqlTest(t)
+	require.NoError(t, p.Connect())
+	row := p.db.QueryRow(ctx, "SELECT count(*) FROM pg_available_extensions WHERE name='postgis'")
+	var n int
+	require.NoError(t, row.Scan(&n))
+	if n == 0 {
+		t.Skipf("postgis extension is not available")
+		t.SkipNow()
+	}
+
+	for _, mode := range []string{"copy", "insert", "simple"} {
+		t.Run(mode, func(t *testing.T) {
+			p := newPostgresqlTest(t)
+			p.GeometryPoints = []string{"location:lat:lon"}
+			p.UseCopy = mode == "copy"
+			p.SimpleProtocol = mode == "simple"
+			require.NoError(t, p.Init())
+			require.NoError(t, p.Connect())
+
+			metrics := []telegraf.Metric{
+				newMetric(t, "", MSS{}, MSI{"lat": 51.5, "lon": -0.1}),
+			}
+			require.NoError(t, p.Write(metrics))
+
+			var lon, lat float64
+			var srid int
+			row := p.db.QueryRow(ctx, "SELECT ST_X(location), ST_Y(location), ST_SRID(location) FROM "+
+				pgx.Identifier{t.Name()}.Sanitize())
+			require.NoError(t, row.Scan(&lon, &lat, &srid))
+			assert.Equal(t, -0.1, lon)
+			assert.Equal(t, 51.5, lat)
+			assert.Equal(t, 4326, srid)
+		})
+	}
+}
+
 // Last ditch effort to find any concurrency issues.
 func TestStressConcurrency(t *testing.T) {
 	metrics := []telegraf.Metric{
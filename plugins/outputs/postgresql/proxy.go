@@ -0,0 +1,127 @@
+package postgresql
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgconn"
+	xproxy "golang.org/x/net/proxy"
+)
+
+// dialFunc adapts a pgconn.DialFunc to the dialer interfaces of golang.org/x/net/proxy.
+type dialFunc pgconn.DialFunc
+
+func (d dialFunc) Dial(network, addr string) (net.Conn, error) {
+	return d(context.Background(), network, addr)
+}
+
+func (d dialFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d(ctx, network, addr)
+}
+
+// configureProxy routes the connections through the proxy of http_proxy_url, if set. Host names are resolved by the
+// proxy rather than locally, as they may only be resolvable from behind it.
+func (p *Postgresql) configureProxy() error {
+	if p.HTTPProxyURL == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(p.HTTPProxyURL)
+	if err != nil {
+		return fmt.Errorf("error parsing proxy url %q: %w", p.HTTPProxyURL, err)
+	}
+
+	connConfig := p.dbConfig.ConnConfig
+	direct := dialFunc(connConfig.DialFunc)
+	var proxied pgconn.DialFunc
+	switch proxyURL.Scheme {
+	case "http", "https":
+		proxied = httpConnectDialer{url: proxyURL, forward: direct}.DialContext
+	case "socks5", "socks5h":
+		dialer, err := xproxy.FromURL(proxyURL, direct)
+		if err != nil {
+			return err
+		}
+		proxied = dialer.(xproxy.ContextDialer).DialContext
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+
+	connConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network == "unix" {
+			return direct(ctx, network, addr)
+		}
+		return proxied(ctx, network, addr)
+	}
+	connConfig.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+		return []string{host}, nil
+	}
+	return nil
+}
+
+// httpConnectDialer dials through an HTTP proxy, tunnelling the connection with the CONNECT method.
+type httpConnectDialer struct {
+	url     *url.URL
+	forward dialFunc
+}
+
+func (d httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyAddr := d.url.Host
+	if d.url.Port() == "" {
+		if d.url.Scheme == "https" {
+			proxyAddr = net.JoinHostPort(d.url.Hostname(), "443")
+		} else {
+			proxyAddr = net.JoinHostPort(d.url.Hostname(), "80")
+		}
+	}
+	conn, err := d.forward(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if d.url.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: d.url.Hostname()})
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{}) //nolint:errcheck
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if d.url.User != nil {
+		password, _ := d.url.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.url.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused connection to %s: %s", addr, resp.Status)
+	}
+	// The server doesn't send anything until the client does, so nothing may follow the response.
+	if br.Buffered() > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected data from proxy after connecting to %s", addr)
+	}
+	return conn, nil
+}
@@ -0,0 +1,89 @@
+package postgresql
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresql_configureProxy(t *testing.T) {
+	// The upstream echoes a line back.
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer upstream.Close()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			_, _ = conn.Write([]byte(line))
+			conn.Close()
+		}
+	}()
+
+	var connectHost, authorization string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connectHost = r.Host
+		authorization = r.Header.Get("Proxy-Authorization")
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		target, err := net.Dial("tcp", upstream.Addr().String())
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(target, conn)
+			target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+		conn.Close()
+	}))
+	defer proxyServer.Close()
+
+	p := newPostgresql()
+	p.Connection = "host=db.internal"
+	p.HTTPProxyURL = "http://user:secret@" + proxyServer.Listener.Addr().String()
+	require.NoError(t, p.Init())
+	connConfig := p.dbConfig.ConnConfig
+
+	// Host names aren't resolved locally.
+	hosts, err := connConfig.LookupFunc(ctx, "db.internal")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db.internal"}, hosts)
+
+	conn, err := connConfig.DialFunc(ctx, "tcp", "db.internal:5432")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+	assert.Equal(t, "db.internal:5432", connectHost)
+	assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", authorization)
+
+	p = newPostgresql()
+	p.HTTPProxyURL = "ftp://proxy"
+	assert.Error(t, p.Init())
+
+	p = newPostgresql()
+	p.HTTPProxyURL = "socks5://proxy:1080"
+	require.NoError(t, p.Init())
+}
@@ -0,0 +1,100 @@
+package postgresql
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Values of secondary_mode.
+const (
+	secondaryModeBestEffort = "best_effort"
+	secondaryModeStrict     = "strict"
+)
+
+// initRoutes creates the plugin instances writing to the databases of routes.
+func (p *Postgresql) initRoutes() error {
+	p.routes = make(map[string]*Postgresql, len(p.Routes))
+	for value, conn := range p.Routes {
+		route, err := p.clone(conn, value)
+		if err != nil {
+			return fmt.Errorf("route %q: %w", value, err)
+		}
+		p.routes[value] = route
+	}
+	return nil
+}
+
+// clone creates a plugin instance with the same configuration, other than writing to the database of conn, without
+// routes or a secondary, and keeping its state in files suffixed with suffix. conn may be a connection string, or just
+// the name of a database on the server of connection.
+func (p *Postgresql) clone(conn string, suffix string) (*Postgresql, error) {
+	c := newPostgresql()
+	src, dst := reflect.ValueOf(p).Elem(), reflect.ValueOf(c).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if src.Type().Field(i).IsExported() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	c.RouteTag = ""
+	c.Routes = map[string]string{}
+	c.SecondaryConnection = ""
+
+	// A value which is not a keyword/value connection string, nor a URL, is the name of a database.
+	dbName := ""
+	if strings.Contains(conn, "=") || strings.Contains(conn, "://") {
+		c.Connection = conn
+	} else {
+		dbName = conn
+	}
+	if p.TagCacheFile != "" {
+		c.TagCacheFile = p.TagCacheFile + "." + suffix
+	}
+	if p.DeadLetterFile != "" {
+		c.DeadLetterFile = p.DeadLetterFile + "." + suffix
+	}
+	if p.SpillDirectory != "" {
+		c.SpillDirectory = filepath.Join(p.SpillDirectory, suffix)
+	}
+
+	if err := c.Init(); err != nil {
+		return nil, err
+	}
+	if dbName != "" {
+		c.dbConfig.ConnConfig.Database = dbName
+	}
+	return c, nil
+}
+
+// writeRouted writes the metrics to the databases of routes, by the value of their route_tag tag, and the rest to the
+// database of connection. Each database is written even if another fails, and the first error is returned, so that
+// telegraf retries the whole batch, writing the metrics of the databases which succeeded again.
+func (p *Postgresql) writeRouted(metrics []telegraf.Metric) error {
+	batches := make(map[*Postgresql][]telegraf.Metric)
+	for _, metric := range metrics {
+		target := p
+		if value, ok := metric.GetTag(p.RouteTag); ok {
+			if route, ok := p.routes[value]; ok {
+				target = route
+			}
+		}
+		batches[target] = append(batches[target], metric)
+	}
+
+	var firstErr error
+	for target, batch := range batches {
+		var err error
+		if target == p {
+			err = p.write(batch)
+		} else {
+			err = target.Write(batch)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,48 @@
+package postgresql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresql_routes(t *testing.T) {
+	p := newPostgresql()
+	p.Connection = "host=foo dbname=main"
+	p.Routes = map[string]string{"a": "metrics_a", "b": "host=bar dbname=b"}
+	require.Error(t, p.Init())
+
+	p.RouteTag = "tenant"
+	p.TagCacheFile = "/var/lib/telegraf/tag_cache"
+	require.NoError(t, p.Init())
+	require.Len(t, p.routes, 2)
+
+	a := p.routes["a"]
+	assert.Equal(t, "foo", a.dbConfig.ConnConfig.Host)
+	assert.Equal(t, "metrics_a", a.dbConfig.ConnConfig.Database)
+	assert.Equal(t, "/var/lib/telegraf/tag_cache.a", a.TagCacheFile)
+	assert.Empty(t, a.routes)
+
+	b := p.routes["b"]
+	assert.Equal(t, "bar", b.dbConfig.ConnConfig.Host)
+	assert.Equal(t, "b", b.dbConfig.ConnConfig.Database)
+	assert.Equal(t, "main", p.dbConfig.ConnConfig.Database)
+}
+
+func TestPostgresql_secondary(t *testing.T) {
+	p := newPostgresql()
+	p.Connection = "host=foo dbname=main"
+	p.SecondaryConnection = "standby"
+	p.SecondaryMode = "sometimes"
+	require.Error(t, p.Init())
+
+	p.SecondaryMode = "strict"
+	p.SpillDirectory = "/var/lib/telegraf/spill"
+	require.NoError(t, p.Init())
+	require.NotNil(t, p.secondary)
+	assert.Equal(t, "foo", p.secondary.dbConfig.ConnConfig.Host)
+	assert.Equal(t, "standby", p.secondary.dbConfig.ConnConfig.Database)
+	assert.Equal(t, "/var/lib/telegraf/spill/secondary", p.secondary.SpillDirectory)
+	assert.Nil(t, p.secondary.secondary)
+}
@@ -0,0 +1,122 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/influxdata/telegraf"
+)
+
+// salvageRow is a row of a table source, along with the metric it was built from, and the error it was dropped due to.
+type salvageRow struct {
+	metric telegraf.Metric
+	values []interface{}
+	err    error
+}
+
+// copySalvaging copies the metrics of the table source into its table as copyFrom does, but with salvage_rows, so
+// that when the copy fails with a permanent error, such as due to a value which can't be converted to the type of its
+// column, only the offending rows are dropped. The rows are split in halves, each copied within its own savepoint,
+// recursing into those which fail, down to the single rows at fault. The savepoints are within a single transaction,
+// and the rows only dropped once it commits, so that upon a temporary error nothing is written or dropped, and the
+// table source can be retried as a whole.
+func (p *Postgresql) copySalvaging(ctx context.Context, db dbh, ident pgx.Identifier, tableSource *TableSource) error {
+	var rows, dropped []salvageRow
+	for tableSource.Next() {
+		values, err := tableSource.Values()
+		metric := tableSource.metrics[tableSource.cursor]
+		if err != nil {
+			dropped = append(dropped, salvageRow{metric: metric, err: err})
+			continue
+		}
+		// The values are only valid until the next row, so are copied.
+		rows = append(rows, salvageRow{metric: metric, values: append([]interface{}(nil), values...)})
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+	failed, err := p.copySalvage(ctx, tx, ident, tableSource.ColumnNames(), rows, true)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, row := range append(dropped, failed...) {
+		p.dropRow(ctx, db, row.metric, row.err)
+	}
+	return nil
+}
+
+// copySalvage copies the rows within a savepoint, splitting them upon a permanent error, and returns the rows at
+// fault, with their errors. Errors due to the table or a column not existing are returned on the first attempt, to be
+// handled by checkStaleTable, as they apply to all rows.
+func (p *Postgresql) copySalvage(
+	ctx context.Context,
+	db dbh,
+	ident pgx.Identifier,
+	colNames []string,
+	rows []salvageRow,
+	first bool,
+) ([]salvageRow, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	sp, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sp.Rollback(ctx) //nolint:errcheck
+	values := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = row.values
+	}
+	err = p.copyFrom(ctx, sp, ident, colNames, pgx.CopyFromRows(values))
+	if err == nil {
+		return nil, sp.Commit(ctx)
+	}
+	if p.isTemporary(err) || (first && isUndefinedError(err)) {
+		return nil, err
+	}
+	if err := sp.Rollback(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 1 {
+		rows[0].err = err
+		return rows, nil
+	}
+	mid := len(rows) / 2
+	failed, err := p.copySalvage(ctx, db, ident, colNames, rows[:mid], false)
+	if err != nil {
+		return nil, err
+	}
+	failedRest, err := p.copySalvage(ctx, db, ident, colNames, rows[mid:], false)
+	if err != nil {
+		return nil, err
+	}
+	return append(failed, failedRest...), nil
+}
+
+// dropRow drops the metric of a row which can't be written, due to the permanent error.
+func (p *Postgresql) dropRow(ctx context.Context, db dbh, metric telegraf.Metric, err error) {
+	p.Logger.Errorf("write error (permanent, dropping row): %v", err)
+	p.dropMetrics(ctx, db, []telegraf.Metric{metric}, err)
+}
+
+// isUndefinedError reports whether the error is due to a table or column not existing.
+func isUndefinedError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "42P01" || pgErr.Code == "42703" // undefined_table, undefined_column
+}
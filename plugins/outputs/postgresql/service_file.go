@@ -0,0 +1,56 @@
+package postgresql
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// systemServiceFiles returns the system-wide connection service files, in the order they're looked for. libpq reads
+// pg_service.conf in PGSYSCONFDIR, or else in a directory decided at compile time, usually one of the latter.
+func systemServiceFiles() []string {
+	var paths []string
+	if dir := os.Getenv("PGSYSCONFDIR"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "pg_service.conf"))
+	}
+	return append(paths, "/etc/postgresql-common/pg_service.conf", "/etc/pg_service.conf")
+}
+
+// withServiceFile returns the connection with the system-wide service file as its servicefile, when the service of
+// the connection can't otherwise be found. pgx only reads the service from the per-user file, ~/.pg_service.conf,
+// whereas libpq falls back to the system-wide one. The connection is returned unchanged when it parses, or has an
+// explicit service file.
+func withServiceFile(connection string) string {
+	_, err := pgconn.ParseConfig(connection)
+	if err == nil || !strings.Contains(err.Error(), "service") ||
+		strings.Contains(connection, "servicefile") || os.Getenv("PGSERVICEFILE") != "" {
+		return connection
+	}
+
+	for _, path := range systemServiceFiles() {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		var withPath string
+		if strings.HasPrefix(connection, "postgres://") || strings.HasPrefix(connection, "postgresql://") {
+			u, err := url.Parse(connection)
+			if err != nil {
+				return connection
+			}
+			query := u.Query()
+			query.Set("servicefile", path)
+			u.RawQuery = query.Encode()
+			withPath = u.String()
+		} else {
+			quoted := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(path)
+			withPath = strings.TrimSpace(connection + " servicefile='" + quoted + "'")
+		}
+		if _, err := pgconn.ParseConfig(withPath); err == nil {
+			return withPath
+		}
+	}
+	return connection
+}
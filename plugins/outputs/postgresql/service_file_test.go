@@ -0,0 +1,42 @@
+package postgresql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresql_withServiceFile(t *testing.T) {
+	if os.Getenv("PGSERVICEFILE") != "" {
+		t.Skip("PGSERVICEFILE is set")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pg_service.conf"),
+		[]byte("[metrics]\nhost=db.example.com\nport=5433\ndbname=telegraf\nuser=writer\n"), 0600))
+	t.Setenv("PGSYSCONFDIR", dir)
+
+	p := newPostgresql()
+	p.Connection = "service=metrics application_name=test"
+	require.NoError(t, p.Init())
+	connConfig := p.dbConfig.ConnConfig
+	assert.Equal(t, "db.example.com", connConfig.Host)
+	assert.EqualValues(t, 5433, connConfig.Port)
+	assert.Equal(t, "telegraf", connConfig.Database)
+	assert.Equal(t, "writer", connConfig.User)
+	assert.Equal(t, "test", connConfig.RuntimeParams["application_name"])
+
+	p = newPostgresql()
+	p.Connection = "postgres:///?service=metrics"
+	require.NoError(t, p.Init())
+	assert.Equal(t, "db.example.com", p.dbConfig.ConnConfig.Host)
+
+	// Connections not using a service are left as they are.
+	assert.Equal(t, "host=db", withServiceFile("host=db"))
+
+	p = newPostgresql()
+	p.Connection = "service=nonexistent"
+	assert.Error(t, p.Init())
+}
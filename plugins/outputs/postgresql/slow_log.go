@@ -0,0 +1,38 @@
+package postgresql
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// slowLogDB wraps a dbh, logging statements passed to Exec which take longer than log_slow_statements.
+type slowLogDB struct {
+	dbh
+	postgresql *Postgresql
+}
+
+func (d slowLogDB) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	start := time.Now()
+	tag, err := d.dbh.Exec(ctx, sql, arguments...)
+	d.postgresql.logSlow(start, sql, tag.RowsAffected())
+	return tag, err
+}
+
+// logSlow logs the statement, started at start, if it took longer than log_slow_statements. The number of rows is
+// omitted when negative.
+func (p *Postgresql) logSlow(start time.Time, stmt string, rows int64) {
+	if p.LogSlowStatements <= 0 {
+		return
+	}
+	elapsed := time.Since(start)
+	if elapsed <= time.Duration(p.LogSlowStatements) {
+		return
+	}
+	if rows < 0 {
+		p.Logger.Warnf("Slow statement (%s): %s", elapsed.Round(time.Millisecond), stmt)
+		return
+	}
+	p.Logger.Warnf("Slow statement (%s, %d rows): %s", elapsed.Round(time.Millisecond), rows, stmt)
+}
@@ -0,0 +1,33 @@
+package postgresql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestLogSlow(t *testing.T) {
+	p := newPostgresql()
+	logger := NewLogAccumulator(t)
+	p.Logger = logger
+	require.NoError(t, p.Init())
+
+	// Disabled by default.
+	p.logSlow(time.Now().Add(-time.Hour), "COPY foo", 1)
+	assert.Empty(t, logger.Logs())
+
+	p.LogSlowStatements = config.Duration(time.Second)
+	p.logSlow(time.Now(), "COPY foo", 1)
+	assert.Empty(t, logger.Logs())
+
+	p.logSlow(time.Now().Add(-2*time.Second), "COPY foo", 3)
+	p.logSlow(time.Now().Add(-2*time.Second), "CREATE TABLE foo ()", -1)
+	logs := logger.Logs()
+	require.Len(t, logs, 2)
+	assert.Regexp(t, `^warn: Slow statement \(2(\.\d+)?s, 3 rows\): COPY foo$`, logs[0].String())
+	assert.Regexp(t, `^warn: Slow statement \(2(\.\d+)?s\): CREATE TABLE foo \(\)$`, logs[1].String())
+}
@@ -0,0 +1,142 @@
+package postgresql
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	influxSerializer "github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+// spillSuffix is the suffix of the segment files in spill_directory. Each holds the metrics of one failed write, in
+// line protocol, and is named by a sequence number, zero padded so that the files sort in the order written.
+const spillSuffix = ".lp"
+
+// loadSpill finds the segments left in spill_directory, such as by a previous run, creating the directory if needed.
+func (p *Postgresql) loadSpill() error {
+	if err := os.MkdirAll(p.SpillDirectory, 0750); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(p.SpillDirectory)
+	if err != nil {
+		return err
+	}
+
+	p.spillSegments = nil
+	p.spillSize = 0
+	p.spillSeq = 0
+	for _, entry := range entries {
+		name := entry.Name()
+		seq, err := strconv.Atoi(strings.TrimSuffix(name, spillSuffix))
+		if !strings.HasSuffix(name, spillSuffix) || err != nil || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		p.spillSegments = append(p.spillSegments, filepath.Join(p.SpillDirectory, name))
+		p.spillSize += info.Size()
+		if seq >= p.spillSeq {
+			p.spillSeq = seq + 1
+		}
+	}
+	sort.Strings(p.spillSegments)
+	if len(p.spillSegments) > 0 {
+		p.Logger.Infof("Found %d spilled writes in %s", len(p.spillSegments), p.SpillDirectory)
+	}
+	return nil
+}
+
+// writeSpilling writes the metrics after any spilled ones. If either fails with a temporary error, the metrics are
+// spilled instead.
+func (p *Postgresql) writeSpilling(metrics []telegraf.Metric) error {
+	p.spillMutex.Lock()
+	defer p.spillMutex.Unlock()
+
+	err := p.drainSpill()
+	if err == nil {
+		err = p.writeMetrics(metrics)
+	}
+	if err == nil || !p.isTemporary(err) {
+		return err
+	}
+	return p.spill(metrics, err)
+}
+
+// drainSpill writes the spilled metrics to the database, oldest first, removing each segment once written. Spilled
+// metrics which fail with a permanent error are dropped, as they would otherwise block all later writes.
+func (p *Postgresql) drainSpill() error {
+	for len(p.spillSegments) > 0 {
+		path := p.spillSegments[0]
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		metrics, err := influx.NewParser(influx.NewMetricHandler()).Parse(b)
+		if err != nil {
+			p.Logger.Errorf("Couldn't parse spilled metrics in %s, dropping them\n%v", path, err)
+		} else if err := p.writeMetrics(metrics); err != nil {
+			if p.isTemporary(err) {
+				return err
+			}
+			p.Logger.Errorf("write error (permanent, dropping spilled metrics): %v", err)
+			p.dropMetrics(p.dbContext, p.conn(), metrics, err)
+		}
+
+		// Failing to remove the segment would write its metrics again, so stop until it can be removed.
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		p.spillSegments = p.spillSegments[1:]
+		p.spillSize -= int64(len(b))
+		p.Logger.Debugf("Wrote %d spilled metrics from %s", len(metrics), path)
+	}
+	return nil
+}
+
+// spill writes the metrics to a new segment in spill_directory. If the segment would exceed spill_max_size, or can't
+// be written, writeErr is returned instead, leaving the metrics for telegraf to retry.
+func (p *Postgresql) spill(metrics []telegraf.Metric, writeErr error) error {
+	var buf bytes.Buffer
+	serializer := influxSerializer.NewSerializer()
+	serializer.SetFieldTypeSupport(influxSerializer.UintSupport)
+	for _, metric := range metrics {
+		b, err := serializer.Serialize(metric)
+		if err != nil {
+			// Such as a metric without fields, which has nothing to write anyway.
+			p.Logger.Debugf("Couldn't serialize metric to spill: %v", err)
+			continue
+		}
+		buf.Write(b)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	if p.spillSize+int64(buf.Len()) > int64(p.SpillMaxSize) {
+		return writeErr
+	}
+
+	// The segment is written under a temporary name, so that one left partially written by a crash is not read back.
+	path := filepath.Join(p.SpillDirectory, fmt.Sprintf("%020d%s", p.spillSeq, spillSuffix))
+	if err := os.WriteFile(path+".tmp", buf.Bytes(), 0640); err != nil {
+		p.Logger.Errorf("Couldn't spill metrics to %s\n%v", path, err)
+		return writeErr
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		p.Logger.Errorf("Couldn't spill metrics to %s\n%v", path, err)
+		return writeErr
+	}
+	p.spillSegments = append(p.spillSegments, path)
+	p.spillSize += int64(buf.Len())
+	p.spillSeq++
+	p.Logger.Warnf("Spilled %d metrics to %s after write error: %v", len(metrics), path, writeErr)
+	return nil
+}
@@ -0,0 +1,64 @@
+package postgresql
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+)
+
+func TestSpill(t *testing.T) {
+	p := newPostgresql()
+	p.Logger = NewLogAccumulator(t)
+	p.SpillDirectory = t.TempDir()
+	require.NoError(t, p.Init())
+	require.NoError(t, p.loadSpill())
+	assert.Empty(t, p.spillSegments)
+
+	writeErr := fmt.Errorf("connection refused")
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"pop": "tag"}, MSI{"a": 1}),
+		newMetric(t, "", MSS{"pop": "tag"}, MSI{"b": uint64(2)}),
+	}
+	require.NoError(t, p.spill(metrics, writeErr))
+	require.NoError(t, p.spill(metrics[:1], writeErr))
+
+	// The segments are found again, such as after a restart.
+	size := p.spillSize
+	p.spillSegments = nil
+	require.NoError(t, p.loadSpill())
+	require.Len(t, p.spillSegments, 2)
+	assert.Equal(t, size, p.spillSize)
+	assert.Equal(t, 2, p.spillSeq)
+
+	b, err := os.ReadFile(p.spillSegments[0])
+	require.NoError(t, err)
+	spilled, err := influx.NewParser(influx.NewMetricHandler()).Parse(b)
+	require.NoError(t, err)
+	require.Len(t, spilled, 2)
+	for i := range metrics {
+		assert.Equal(t, metrics[i].Name(), spilled[i].Name())
+		assert.Equal(t, metrics[i].Tags(), spilled[i].Tags())
+		assert.Equal(t, metrics[i].Fields(), spilled[i].Fields())
+		assert.Equal(t, metrics[i].Time().UnixNano(), spilled[i].Time().UnixNano())
+	}
+
+	// Once full, the write error is returned so that telegraf keeps the metrics.
+	p.SpillMaxSize = config.Size(p.spillSize)
+	assert.Equal(t, writeErr, p.spill(metrics, writeErr))
+	assert.Len(t, p.spillSegments, 2)
+}
+
+func TestPostgresql_spillCoalesce(t *testing.T) {
+	p := newPostgresql()
+	p.SpillDirectory = t.TempDir()
+	p.CoalesceInterval = config.Duration(time.Second)
+	assert.Error(t, p.Init())
+}
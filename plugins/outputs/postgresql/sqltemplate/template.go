@@ -1,8 +1,8 @@
 /*
 
 Templates are used for creation of the SQL used when creating and modifying tables. These templates are specified within
-the configuration as the parameters 'create_templates', 'add_column_templates, 'tag_table_create_templates', and
-'tag_table_add_column_templates'.
+the configuration as the parameters 'create_templates', 'create_index_templates', 'add_column_templates,
+'widen_column_templates', 'tag_table_create_templates', and 'tag_table_add_column_templates'.
 
 The templating functionality behaves the same in all cases. However the variables will differ.
 
@@ -16,7 +16,8 @@ The following variables are available within all template executions:
 
  * columns - A Columns object of the new columns being added to the
    table (all columns in the case of a new table, and new columns in the case
-   of existing table).
+   of existing table). In the case of 'widen_column_templates', the columns
+   being widened, with their new type.
 
  * allColumns - A Columns object of all the columns (both old and new)
    of the table. In the case of a new table, this is the same as `columns`.
@@ -29,6 +30,16 @@ The following variables are available within all template executions:
    tags. In the case of TagsAsForeignKeys and `table` is the metrics table,
    then `tagTable` is the table containing the tags for it.
 
+ * tablespace - The quoted identifier of the configured tablespace, or an
+   empty string if no tablespace is configured. E.G.
+   `CREATE TABLE {{.table}} ({{.columns}}){{if .tablespace}} TABLESPACE {{.tablespace}}{{end}}`
+
+ * metric - A Metric object of a sample of the metrics being written, which
+   caused the table to be created or modified. Its tags and fields may be used
+   for value driven DDL. As referencing a missing map key is an error, the
+   Tag and Field helpers should be used for tags or fields the metric may lack. E.G.
+   `CREATE TABLE {{.table}} ({{.columns}}) TABLESPACE {{ .metric.Tag "region" | default "pg_default" | quoteIdentifier }}`
+
 Each object has helper methods that may be used within the template. See the documentation for the appropriate type.
 
 When the object is interpolated without a helper, it is automatically converted to a string through its String() method.
@@ -44,6 +55,32 @@ In addition, the following functions are also available:
 
   * quoteLiteral - Quotes the input string as a Postgres literal.
 
+  * regexReplace - Replaces all matches of a regular expression within the input. The input is the last argument so
+    that it may be used in a pipeline. E.G.
+    `{{ .table.Name | regexReplace "_[0-9]+$" "" }}`
+
+  * envDefault - Returns the value of the named environment variable, or the given fallback when the variable is unset
+    or empty. E.G.
+    `CREATE TABLE {{.table}} ({{.columns}}) TABLESPACE {{ envDefault "PG_TABLESPACE" "pg_default" | quoteIdentifier }}`
+
+Of the Sprig functions, the following are commonly useful when writing DDL:
+
+  * default - Returns the given default when the input is empty. E.G. `{{ .tablespace | default "pg_default" }}`
+
+  * env - Returns the value of the named environment variable.
+
+  * upper, lower, snakecase - Change the case of the input string. E.G. `{{ .table.Name | lower | quoteIdentifier }}`
+
+  * regexMatch - Reports whether the input string matches a regular expression. E.G.
+    `{{ if regexMatch "^cpu" .table.Name }}...{{ end }}`
+
+  * list, dict, has, hasKey, pluck, without, uniq, sortAlpha - Build and query lists and dictionaries, such as to
+    look up a per-table setting with the builtin index function. E.G.
+    `{{ $intervals := dict "cpu" "1h" "disk" "1d" }}{{ index $intervals .table.Name | default "1d" }}`
+
+  * trimPrefix, trimSuffix, replace, split, splitList, contains, hasPrefix, hasSuffix - Manipulate strings. E.G.
+    `{{ .table.Name | trimSuffix "_total" }}`
+
 
 Examples
 
@@ -115,6 +152,8 @@ import (
 	"encoding/base32"
 	"fmt"
 	"hash/fnv"
+	"os"
+	"regexp"
 	"strings"
 	"text/template"
 	"unsafe"
@@ -127,6 +166,8 @@ import (
 var templateFuncs = map[string]interface{}{
 	"quoteIdentifier": QuoteIdentifier,
 	"quoteLiteral":    QuoteLiteral,
+	"regexReplace":    RegexReplace,
+	"envDefault":      EnvDefault,
 }
 
 func asString(obj interface{}) string {
@@ -156,6 +197,28 @@ func QuoteLiteral(str interface{}) string {
 	return utils.QuoteLiteral(asString(str))
 }
 
+// RegexReplace replaces all matches of the regular expression pattern within the given string with repl. Within repl,
+// `$1` style references are expanded to the corresponding submatch.
+//
+// RegexReplace is accessible within templates as 'regexReplace'.
+func RegexReplace(pattern string, repl string, str interface{}) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(asString(str), repl), nil
+}
+
+// EnvDefault returns the value of the named environment variable, or the fallback if the variable is unset or empty.
+//
+// EnvDefault is accessible within templates as 'envDefault'.
+func EnvDefault(name string, fallback interface{}) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return asString(fallback)
+}
+
 // Table is an object which represents a Postgres table.
 type Table struct {
 	Schema  string
@@ -213,6 +276,25 @@ func (tbl *Table) WithSuffix(suffixes ...string) *Table {
 	return tblNew
 }
 
+// Metric is an object which represents a sample of the metrics being written to a table.
+type Metric struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]interface{}
+	// Types maps the name of each field column to its Postgres data type.
+	Types map[string]string
+}
+
+// Tag returns the value of the given tag, or an empty string if the metric lacks it.
+func (m *Metric) Tag(key string) string {
+	return m.Tags[key]
+}
+
+// Field returns the value of the given field, or nil if the metric lacks it.
+func (m *Metric) Field(key string) interface{} {
+	return m.Fields[key]
+}
+
 // A Column is an object which represents a Postgres column.
 type Column utils.Column
 
@@ -325,6 +407,14 @@ func (cols Columns) Sorted() Columns {
 	return newCols
 }
 
+// sortedByOrder returns a sorted copy of Columns, the same as Sorted, except that columns named in order are placed
+// first, in the given order.
+func (cols Columns) sortedByOrder(order []string) Columns {
+	newCols := append([]Column{}, cols...)
+	(*utils.ColumnList)(unsafe.Pointer(&newCols)).SortByOrder(order)
+	return newCols
+}
+
 // Concat returns a copy of Columns with the given tcsList appended to the end.
 func (cols Columns) Concat(tcsList ...Columns) Columns {
 	tcsNew := append(Columns{}, cols...)
@@ -351,6 +441,21 @@ TCS:
 	return tcsNew
 }
 
+// Without returns a copy of Columns excluding any columns with the same name as a column in tcsExclude.
+func (cols Columns) Without(tcsExclude Columns) Columns {
+	var newCols []Column
+TCS:
+	for _, tc := range cols {
+		for _, tcExclude := range tcsExclude {
+			if tc.Name == tcExclude.Name {
+				continue TCS
+			}
+		}
+		newCols = append(newCols, tc)
+	}
+	return newCols
+}
+
 // Tags returns a Columns list of the columns which are tags.
 func (cols Columns) Tags() Columns {
 	var newCols []Column
@@ -400,14 +505,32 @@ func (t *Template) UnmarshalText(text []byte) error {
 	return nil
 }
 
-func (t *Template) Render(table *Table, newColumns []utils.Column, metricTable *Table, tagTable *Table) ([]byte, error) {
-	tcs := NewColumns(newColumns).Sorted()
+// Render executes the template. Columns are ordered as per columnOrder, and then by the default sort order.
+//nolint:revive
+func (t *Template) Render(
+	table *Table,
+	newColumns []utils.Column,
+	metricTable *Table,
+	tagTable *Table,
+	tablespace string,
+	columnOrder []string,
+	metric *Metric,
+) ([]byte, error) {
+	if metric == nil {
+		metric = &Metric{}
+	}
+	tcs := NewColumns(newColumns).sortedByOrder(columnOrder)
 	data := map[string]interface{}{
 		"table":       table,
 		"columns":     tcs,
-		"allColumns":  tcs.Concat(table.Columns).Sorted(),
+		"allColumns":  tcs.Concat(table.Columns.Without(tcs)).sortedByOrder(columnOrder),
 		"metricTable": metricTable,
 		"tagTable":    tagTable,
+		"tablespace":  "",
+		"metric":      metric,
+	}
+	if tablespace != "" {
+		data["tablespace"] = QuoteIdentifier(tablespace)
 	}
 
 	buf := bytes.NewBuffer(nil)
@@ -0,0 +1,63 @@
+package postgresql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// readOnlyError is returned by writes while backing off after the database was found to be read-only.
+type readOnlyError struct {
+	error
+}
+
+func (e readOnlyError) Unwrap() error {
+	return e.error
+}
+
+// isReadOnlyError reports whether the error is due to the database being read-only, as a hot standby is. After a
+// failover, the former primary may come back as a standby, on the same address.
+func isReadOnlyError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "25006": // read_only_sql_transaction
+		return true
+	case "55000": // object_not_in_prerequisite_state
+		return strings.Contains(pgErr.Message, "recovery is in progress")
+	}
+	return false
+}
+
+// readOnlyDetected handles a write failing as the database is read-only. The idle connections are closed, so that
+// those made by the next write go to the primary, when connection lists several hosts with
+// target_session_attrs=read-write, or re-resolve its host name. Writes fail without being attempted until
+// read_only_retry_interval has passed, rather than failing on the standby in the meantime.
+func (p *Postgresql) readOnlyDetected(err error) {
+	p.readOnlyMutex.Lock()
+	defer p.readOnlyMutex.Unlock()
+	if time.Now().Before(p.readOnlyUntil) {
+		return
+	}
+	p.readOnlyUntil = time.Now().Add(time.Duration(p.ReadOnlyRetryInterval))
+	p.Logger.Warnf("Database is read-only, such as a standby after a failover, reconnecting and retrying in %s: %v",
+		time.Duration(p.ReadOnlyRetryInterval), err)
+	if p.db != nil {
+		p.resetPool(p.dbContext, p.db)
+	}
+}
+
+// readOnlyBackoff returns a readOnlyError while backing off after the database was found to be read-only.
+func (p *Postgresql) readOnlyBackoff() error {
+	p.readOnlyMutex.Lock()
+	defer p.readOnlyMutex.Unlock()
+	if wait := time.Until(p.readOnlyUntil); wait > 0 {
+		return readOnlyError{fmt.Errorf("database is read-only, retrying in %s", wait.Round(time.Second))}
+	}
+	return nil
+}
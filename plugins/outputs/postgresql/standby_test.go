@@ -0,0 +1,51 @@
+package postgresql
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestIsReadOnlyError(t *testing.T) {
+	readOnly := &pgconn.PgError{Code: "25006", Message: "cannot execute INSERT in a read-only transaction"}
+	assert.True(t, isReadOnlyError(fmt.Errorf("writing: %w", readOnly)))
+	assert.True(t, isTempError(readOnly))
+
+	recovery := &pgconn.PgError{Code: "55000", Message: "recovery is in progress"}
+	assert.True(t, isReadOnlyError(recovery))
+	assert.True(t, isTempError(recovery))
+
+	other := &pgconn.PgError{Code: "55000", Message: "cannot drop table because it is in use"}
+	assert.False(t, isReadOnlyError(other))
+	assert.False(t, isTempError(other))
+	assert.False(t, isReadOnlyError(fmt.Errorf("connection refused")))
+}
+
+func TestPostgresql_readOnlyBackoff(t *testing.T) {
+	p := newPostgresql()
+	p.ReadOnlyRetryInterval = config.Duration(time.Hour)
+	require.NoError(t, p.Init())
+	logger := NewLogAccumulator(t)
+	p.Logger = logger
+	require.NoError(t, p.readOnlyBackoff())
+
+	p.readOnlyDetected(&pgconn.PgError{Code: "25006", Message: "cannot execute INSERT in a read-only transaction"})
+	require.Len(t, logger.Logs(), 1)
+	err := p.writeMetrics([]telegraf.Metric{newMetric(t, "", nil, MSI{"v": 1})})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read-only")
+	assert.True(t, p.isTemporary(err))
+
+	// Failures of writes already in progress don't extend the backoff, or log again.
+	until := p.readOnlyUntil
+	p.readOnlyDetected(&pgconn.PgError{Code: "25006"})
+	assert.Equal(t, until, p.readOnlyUntil)
+	assert.Len(t, logger.Logs(), 1)
+}
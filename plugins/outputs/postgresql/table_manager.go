@@ -2,11 +2,13 @@ package postgresql
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgconn"
 
 	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
 	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
@@ -18,6 +20,13 @@ const schemaAdvisoryLockID int64 = 5705450890675909945
 type tableState struct {
 	name    string
 	columns map[string]utils.Column
+	// keyColumns are the columns of the primary key or unique constraint, in index order.
+	keyColumns []string
+	// cachedAt is when the cached structure was last discarded, for expiry with table_cache_ttl.
+	cachedAt time.Time
+	// staleRetried is set once a write has been retried due to the cached structure being out of date, until a write
+	// succeeds. It is kept when the cached structure is cleared.
+	staleRetried bool
 	sync.RWMutex
 }
 
@@ -27,6 +36,10 @@ type TableManager struct {
 	// map[tableName]map[columnName]utils.Column
 	tables      map[string]*tableState
 	tablesMutex sync.Mutex
+
+	// map[tagKey]map[value]bool of the values known to exist in the enum types of enum_tags.
+	enumValues      map[string]map[string]bool
+	enumValuesMutex sync.Mutex
 }
 
 // NewTableManager returns an instance of the tables.Manager interface
@@ -35,6 +48,7 @@ func NewTableManager(postgresql *Postgresql) *TableManager {
 	return &TableManager{
 		Postgresql: postgresql,
 		tables:     make(map[string]*tableState),
+		enumValues: make(map[string]map[string]bool),
 	}
 }
 
@@ -44,6 +58,7 @@ func (tm *TableManager) ClearTableCache() {
 	for _, tbl := range tm.tables {
 		tbl.Lock()
 		tbl.columns = nil
+		tbl.keyColumns = nil
 		tbl.Unlock()
 	}
 	tm.tablesMutex.Unlock()
@@ -53,17 +68,101 @@ func (tm *TableManager) ClearTableCache() {
 	}
 }
 
+// clearTableState clears the cached structure of the named table.
+func (tm *TableManager) clearTableState(name string) {
+	tbl := tm.table(name)
+	tbl.Lock()
+	tbl.columns = nil
+	tbl.keyColumns = nil
+	tbl.Unlock()
+}
+
+// markStaleRetry records that a write to the named table is retried due to its cached structure being out of date. It
+// returns false if one already was, without a write to the table having succeeded since.
+func (tm *TableManager) markStaleRetry(name string) bool {
+	tbl := tm.table(name)
+	tbl.Lock()
+	defer tbl.Unlock()
+	if tbl.staleRetried {
+		return false
+	}
+	tbl.staleRetried = true
+	return true
+}
+
+// clearStaleRetry records that a write to the named table succeeded.
+func (tm *TableManager) clearStaleRetry(name string) {
+	tbl := tm.table(name)
+	tbl.Lock()
+	tbl.staleRetried = false
+	tbl.Unlock()
+}
+
 func (tm *TableManager) table(name string) *tableState {
 	tm.tablesMutex.Lock()
 	tbl := tm.tables[name]
 	if tbl == nil {
-		tbl = &tableState{name: name}
+		tbl = &tableState{name: name, cachedAt: time.Now()}
 		tm.tables[name] = tbl
+	} else if tm.TableCacheTTL > 0 && time.Since(tbl.cachedAt) > time.Duration(tm.TableCacheTTL) {
+		tbl.Lock()
+		tbl.columns = nil
+		tbl.keyColumns = nil
+		tbl.cachedAt = time.Now()
+		tbl.Unlock()
 	}
 	tm.tablesMutex.Unlock()
 	return tbl
 }
 
+// KeyColumns returns the names of the columns of the table's primary key. If the table has no primary key, the columns
+// of a unique index are returned instead. If the table has neither, the returned list is empty.
+func (tm *TableManager) KeyColumns(ctx context.Context, db dbh, name string) ([]string, error) {
+	tbl := tm.table(name)
+	tbl.RLock()
+	keyCols := tbl.keyColumns
+	tbl.RUnlock()
+	if keyCols != nil {
+		return keyCols, nil
+	}
+
+	rows, err := db.Query(ctx, `
+		WITH idx AS (
+			SELECT indrelid, indkey
+			FROM pg_index
+			WHERE indrelid = $1::regclass AND indisunique AND indpred IS NULL AND indexprs IS NULL
+			ORDER BY indisprimary DESC, indexrelid
+			LIMIT 1
+		)
+		SELECT a.attname
+		FROM idx, unnest(idx.indkey::smallint[]) WITH ORDINALITY AS k(attnum, n)
+		JOIN pg_attribute a ON a.attrelid = idx.indrelid AND a.attnum = k.attnum
+		ORDER BY k.n`, utils.FullTableName(tm.Schema, name).Sanitize())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var colName string
+		if err := rows.Scan(&colName); err != nil {
+			return nil, err
+		}
+		keyCols = append(keyCols, colName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Only cache when found, so that a constraint added later on will be picked up.
+	if len(keyCols) > 0 {
+		tbl.Lock()
+		tbl.keyColumns = keyCols
+		tbl.Unlock()
+	}
+	return keyCols, nil
+}
+
 // MatchSource scans through the metrics, determining what columns are needed for inserting, and ensuring the DB schema matches.
 //
 // If the schema does not match, and schema updates are disabled:
@@ -71,6 +170,17 @@ func (tm *TableManager) table(name string) *tableState {
 // If a tag is missing from the DB, the metric is dropped.
 func (tm *TableManager) MatchSource(ctx context.Context, db dbh, rowSource *TableSource) error {
 	metricTable := tm.table(rowSource.Name())
+	sample := rowSource.SampleMetric()
+	tmpls := tm.tableTemplates(metricTable.name)
+
+	if tm.enumTagsFilter != nil && !tm.NoDDL {
+		// Not through db, which may be the write's transaction: a value added to an enum type cannot be used by the
+		// transaction which added it.
+		if err := tm.ensureEnumValues(ctx, tm.schemaConn(tm.conn()), rowSource); err != nil {
+			return err
+		}
+	}
+
 	var tagTable *tableState
 	if tm.TagsAsForeignKeys {
 		tagTable = tm.table(metricTable.name + tm.TagTableSuffix)
@@ -80,62 +190,319 @@ func (tm *TableManager) MatchSource(ctx context.Context, db dbh, rowSource *Tabl
 			db,
 			tagTable,
 			rowSource.TagTableColumns(),
-			tm.TagTableCreateTemplates,
-			tm.TagTableAddColumnTemplates,
+			tmpls.TagTableCreateTemplates,
+			tmpls.TagTableAddColumnTemplates,
 			metricTable,
 			tagTable,
+			sample,
 		)
 		if err != nil {
-			if isTempError(err) {
+			if tm.isTemporary(err) {
 				return err
 			}
 			tm.Postgresql.Logger.Errorf("permanent error updating schema for %s: %w", tagTable.name, err)
 		}
 
-		if len(missingCols) > 0 {
-			colDefs := make([]string, len(missingCols))
-			for i, col := range missingCols {
-				if err := rowSource.DropColumn(col); err != nil {
-					return fmt.Errorf("metric/table mismatch: Unable to omit field/column from \"%s\": %w", tagTable.name, err)
-				}
-				colDefs[i] = col.Name + " " + col.Type
-			}
-			tm.Logger.Errorf("table '%s' is missing tag columns (dropping metrics): %s",
-				tagTable.name,
-				strings.Join(colDefs, ", "))
+		if err := tm.handleMissingColumns(tagTable, rowSource, missingCols); err != nil {
+			return err
+		}
+		if rowSource.metricsDropped {
+			return nil
+		}
+
+		if err := tm.dropGeneratedColumns(tagTable, rowSource, rowSource.TagColumns()); err != nil {
+			return err
 		}
 	}
 
+	if rowSource.fieldColumnNames != nil {
+		if err := tm.resolveTypeConflicts(ctx, db, metricTable, rowSource); err != nil {
+			return err
+		}
+	}
+
+	createTemplates := tmpls.CreateTemplates
+	if len(createTemplates) > 0 && len(tmpls.CreateIndexTemplates) > 0 {
+		// Index templates run in the same transaction, immediately after the table is created.
+		createTemplates = append(append([]*sqltemplate.Template{}, tmpls.CreateTemplates...), tmpls.CreateIndexTemplates...)
+	}
+
 	missingCols, err := tm.EnsureStructure(
 		ctx,
 		db,
 		metricTable,
 		rowSource.MetricTableColumns(),
-		tm.CreateTemplates,
-		tm.AddColumnTemplates,
+		createTemplates,
+		tmpls.AddColumnTemplates,
 		metricTable,
 		tagTable,
+		sample,
 	)
 	if err != nil {
-		if isTempError(err) {
+		if tm.isTemporary(err) {
 			return err
 		}
 		tm.Postgresql.Logger.Errorf("permanent error updating schema for %s: %w", metricTable.name, err)
 	}
 
-	if len(missingCols) > 0 {
-		colDefs := make([]string, len(missingCols))
-		for i, col := range missingCols {
-			if err := rowSource.DropColumn(col); err != nil {
-				return fmt.Errorf("metric/table mismatch: Unable to omit field/column from \"%s\": %w", metricTable.name, err)
+	if err := tm.handleMissingColumns(metricTable, rowSource, missingCols); err != nil {
+		return err
+	}
+	if rowSource.metricsDropped {
+		return nil
+	}
+
+	if len(tm.WidenColumnTemplates) > 0 {
+		if err := tm.widenColumns(ctx, db, metricTable, rowSource.FieldColumns(), metricTable, tagTable, sample); err != nil {
+			if tm.isTemporary(err) {
+				return err
 			}
-			colDefs[i] = col.Name + " " + col.Type
+			tm.Postgresql.Logger.Errorf("permanent error widening columns for %s: %v", metricTable.name, err)
 		}
-		tm.Logger.Errorf("table '%s' is missing columns (omitting fields): %s",
-			metricTable.name,
-			strings.Join(colDefs, ", "))
 	}
 
+	if err := tm.dropGeneratedColumns(metricTable, rowSource, rowSource.MetricTableColumns()); err != nil {
+		return err
+	}
+
+	metricTable.RLock()
+	rowSource.matchFieldColumnTypes(metricTable.columns)
+	metricTable.RUnlock()
+
+	return nil
+}
+
+// resolveTypeConflicts assigns the field values of the source to columns according to the data types of the table's
+// existing columns, so that values of a conflicting type are written to a separate column.
+func (tm *TableManager) resolveTypeConflicts(ctx context.Context, db dbh, tbl *tableState, rowSource *TableSource) error {
+	tbl.RLock()
+	currCols := tbl.columns
+	tbl.RUnlock()
+
+	if currCols == nil {
+		tbl.Lock()
+		if tbl.columns == nil {
+			cols, err := tm.getColumns(ctx, db, tbl.name)
+			if err != nil {
+				tbl.Unlock()
+				return err
+			}
+			tbl.columns = cols
+		}
+		currCols = tbl.columns
+		tbl.Unlock()
+	}
+
+	rowSource.resolveTypeConflicts(currCols)
+	return nil
+}
+
+// handleMissingColumns applies the schema_mismatch_policy to the columns which are missing from the table, and which
+// could not be added.
+func (tm *TableManager) handleMissingColumns(tbl *tableState, rowSource *TableSource, missingCols []utils.Column) error {
+	if len(missingCols) == 0 {
+		return nil
+	}
+
+	colDefs := make([]string, len(missingCols))
+	for i, col := range missingCols {
+		colDefs[i] = col.Name + " " + col.Type
+	}
+
+	if tm.SchemaMismatchPolicy == "error" {
+		return fmt.Errorf("metric/table mismatch: table '%s' is missing columns: %s", tbl.name, strings.Join(colDefs, ", "))
+	}
+
+	dropMetrics := tm.SchemaMismatchPolicy == "drop_metrics"
+	for _, col := range missingCols {
+		if dropMetrics && (col.Role == utils.TimeColType || col.Role == utils.TagsIDColType) {
+			// Without these the table is unusable. Most likely it does not exist.
+			rowSource.DropMetrics()
+			tm.Logger.Errorf("table '%s' does not exist or is missing critical columns (dropping metrics): %s",
+				tbl.name,
+				strings.Join(colDefs, ", "))
+			return nil
+		}
+	}
+
+	hasTags := false
+	for _, col := range missingCols {
+		var err error
+		if dropMetrics {
+			err = rowSource.DropColumnMetrics(col)
+		} else {
+			err = rowSource.DropColumn(col)
+		}
+		if err != nil {
+			return fmt.Errorf("metric/table mismatch: Unable to omit field/column from \"%s\": %w", tbl.name, err)
+		}
+		hasTags = hasTags || col.Role == utils.TagColType
+	}
+
+	if dropMetrics || hasTags {
+		tm.Logger.Errorf("table '%s' is missing columns (dropping metrics): %s", tbl.name, strings.Join(colDefs, ", "))
+	} else {
+		tm.Logger.Errorf("table '%s' is missing columns (omitting fields): %s", tbl.name, strings.Join(colDefs, ", "))
+	}
+	return nil
+}
+
+// widenColumns alters the type of any table columns which are too narrow to hold the values of the provided columns.
+// Only field columns are widened, and only along the order defined by pgDatatypeWidening.
+//nolint:revive
+func (tm *TableManager) widenColumns(
+	ctx context.Context,
+	db dbh,
+	tbl *tableState,
+	columns []utils.Column,
+	metricsTable *tableState,
+	tagsTable *tableState,
+	metric *sqltemplate.Metric,
+) error {
+	tbl.RLock()
+	narrowCols := diffNarrowColumns(tbl.columns, columns)
+	tbl.RUnlock()
+	if len(narrowCols) == 0 {
+		return nil
+	}
+
+	// Same lock order as EnsureStructure: 1) Tag, 2) Metric
+	if tagsTable != nil {
+		tagsTable.RLock()
+		defer tagsTable.RUnlock()
+	}
+	metricsTable.Lock()
+	defer metricsTable.Unlock()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+	if err := tm.lockSchema(ctx, tx); err != nil {
+		return err
+	}
+
+	currCols, err := tm.getColumns(ctx, tx, tbl.name)
+	if err != nil {
+		return err
+	}
+	tbl.columns = currCols
+	narrowCols = diffNarrowColumns(currCols, columns)
+	if len(narrowCols) == 0 {
+		return nil
+	}
+
+	colDefs := make([]string, len(narrowCols))
+	for i, col := range narrowCols {
+		colDefs[i] = col.Name + " " + currCols[col.Name].Type + " -> " + col.Type
+	}
+	tm.Logger.Infof("widening columns of table '%s': %s", tbl.name, strings.Join(colDefs, ", "))
+
+	ddl := tm.ddlHandle(tx)
+
+	createView := tm.TagsAsForeignKeys && tm.CreateViews
+	if createView {
+		// The type of a column cannot be altered while a view depends on it.
+		if err := tm.dropView(ctx, ddl, metricsTable); err != nil {
+			return err
+		}
+	}
+
+	if err := tm.update(ctx, ddl, tbl, tm.WidenColumnTemplates, narrowCols, metricsTable, tagsTable, metric); err != nil {
+		return err
+	}
+
+	if tm.DDLDryRun {
+		currCols = mergeColumns(currCols, narrowCols)
+	} else if currCols, err = tm.getColumns(ctx, tx, tbl.name); err != nil {
+		return err
+	}
+
+	if createView {
+		if err := tm.refreshView(ctx, ddl, metricsTable, tagsTable, currCols, tagsTable.columns); err != nil {
+			return err
+		}
+	}
+
+	if err := tm.commitSchema(ctx, tx); err != nil {
+		return err
+	}
+
+	tbl.columns = currCols
+	return nil
+}
+
+// dropView drops the view joining the metric table with its tag table, if it exists.
+func (tm *TableManager) dropView(ctx context.Context, db dbh, metricsTable *tableState) error {
+	viewName := utils.FullTableName(tm.Schema, metricsTable.name+tm.ViewSuffix).Sanitize()
+	if _, err := db.Exec(ctx, "DROP VIEW IF EXISTS "+viewName); err != nil {
+		return fmt.Errorf("dropping view %s: %w", viewName, err)
+	}
+	return nil
+}
+
+// refreshView (re)creates the view joining the metric table with its tag table. The view is dropped and created
+// rather than replaced, as CREATE OR REPLACE VIEW cannot handle columns being inserted in the middle of the list.
+// Nothing is done until both tables exist.
+//nolint:revive
+func (tm *TableManager) refreshView(
+	ctx context.Context,
+	db dbh,
+	metricsTable *tableState,
+	tagsTable *tableState,
+	metricCols map[string]utils.Column,
+	tagCols map[string]utils.Column,
+) error {
+	if len(metricCols) == 0 || len(tagCols) == 0 {
+		return nil
+	}
+
+	if err := tm.dropView(ctx, db, metricsTable); err != nil {
+		return err
+	}
+
+	selectors := []string{"m." + utils.QuoteIdentifier(timeColumnName)}
+	for _, col := range sqltemplate.NewColumns(colMapToSlice(tagCols)).Sorted().Tags() {
+		selectors = append(selectors, "t."+utils.QuoteIdentifier(col.Name))
+	}
+	for _, col := range sqltemplate.NewColumns(colMapToSlice(metricCols)).Sorted().Fields() {
+		selectors = append(selectors, "m."+utils.QuoteIdentifier(col.Name))
+	}
+
+	viewName := utils.FullTableName(tm.Schema, metricsTable.name+tm.ViewSuffix).Sanitize()
+	sql := fmt.Sprintf("CREATE VIEW %s AS SELECT %s FROM %s m JOIN %s t USING (%s)",
+		viewName,
+		strings.Join(selectors, ", "),
+		utils.FullTableName(tm.Schema, metricsTable.name).Sanitize(),
+		utils.FullTableName(tm.Schema, tagsTable.name).Sanitize(),
+		utils.QuoteIdentifier(tagIDColumnName),
+	)
+	if _, err := db.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("creating view %s: %w", viewName, err)
+	}
+	return nil
+}
+
+// dropGeneratedColumns omits any of the given source columns which are generated columns within the table. The values
+// of such columns are computed by the database, and attempting to write to them is an error.
+func (tm *TableManager) dropGeneratedColumns(tbl *tableState, rowSource *TableSource, columns []utils.Column) error {
+	tbl.RLock()
+	defer tbl.RUnlock()
+
+	for _, col := range columns {
+		if tblCol, ok := tbl.columns[col.Name]; !ok || !tblCol.Generated {
+			continue
+		}
+		if err := rowSource.DropColumn(col); err != nil {
+			return fmt.Errorf("metric/table mismatch: Unable to omit generated column from \"%s\": %w", tbl.name, err)
+		}
+		if col.Role == utils.TagColType {
+			tm.Logger.Errorf("table '%s' tag column '%s' is generated (dropping metrics)", tbl.name, col.Name)
+		} else {
+			tm.Logger.Debugf("table '%s' column '%s' is generated (omitting field)", tbl.name, col.Name)
+		}
+	}
 	return nil
 }
 
@@ -157,11 +524,12 @@ func (tm *TableManager) EnsureStructure(
 	addColumnsTemplates []*sqltemplate.Template,
 	metricsTable *tableState,
 	tagsTable *tableState,
+	metric *sqltemplate.Metric,
 ) ([]utils.Column, error) {
 	// Sort so that:
 	//   * When we create/alter the table the columns are in a sane order (telegraf gives us the fields in random order)
 	//   * When we display errors about missing columns, the order is also sane, and consistent
-	utils.ColumnList(columns).Sort()
+	utils.ColumnList(columns).SortByOrder(tm.ColumnOrder)
 
 	// rlock, read, runlock, wlock, read, read_db, wlock_db, read_db, write_db, wunlock_db, wunlock
 
@@ -231,7 +599,7 @@ func (tm *TableManager) EnsureStructure(
 	defer tx.Rollback(ctx) //nolint:errcheck
 	// It's possible to have multiple telegraf processes, in which we can't ensure they all lock tables in the same
 	// order. So to prevent possible deadlocks, we have to have a single lock for all schema modifications.
-	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", schemaAdvisoryLockID); err != nil {
+	if err := tm.lockSchema(ctx, tx); err != nil {
 		return missingCols, err
 	}
 
@@ -249,25 +617,44 @@ func (tm *TableManager) EnsureStructure(
 
 	// write_db
 	var tmpls []*sqltemplate.Template
-	if len(currCols) == 0 {
+	created := len(currCols) == 0
+	if created {
 		tmpls = createTemplates
 	} else {
 		tmpls = addColumnsTemplates
 	}
-	if err := tm.update(ctx, tx, tbl, tmpls, missingCols, metricsTable, tagsTable); err != nil {
+	ddl := tm.ddlHandle(tx)
+	if err := tm.update(ctx, ddl, tbl, tmpls, missingCols, metricsTable, tagsTable, metric); err != nil {
 		return missingCols, err
 	}
 
-	if currCols, err = tm.getColumns(ctx, tx, tbl.name); err != nil {
+	if tm.DDLDryRun {
+		// Nothing was changed, so track the structure the table would have had.
+		currCols = mergeColumns(currCols, missingCols)
+	} else if currCols, err = tm.getColumns(ctx, tx, tbl.name); err != nil {
 		return missingCols, err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
+	if tm.TagsAsForeignKeys && tm.CreateViews {
+		metricCols, tagCols := currCols, tagsTable.columns
+		if tbl == tagsTable {
+			metricCols, tagCols = metricsTable.columns, currCols
+		}
+		if err := tm.refreshView(ctx, ddl, metricsTable, tagsTable, metricCols, tagCols); err != nil {
+			return missingCols, err
+		}
+	}
+
+	if err := tm.commitSchema(ctx, tx); err != nil {
 		return missingCols, err
 	}
 
 	tbl.columns = currCols
 
+	if created {
+		tm.postCreate(ctx, db, tbl, metricsTable, tagsTable, metric)
+	}
+
 	// wunlock_db (deferred)
 	// wunlock (deferred)
 
@@ -275,11 +662,26 @@ func (tm *TableManager) EnsureStructure(
 }
 
 func (tm *TableManager) getColumns(ctx context.Context, db dbh, name string) (map[string]utils.Column, error) {
+	if tm.dialect.noInformationSchema {
+		return tm.showColumns(ctx, db, name)
+	}
+
+	generated := "is_generated = 'ALWAYS'"
+	if tm.dialect.noGeneratedColumns {
+		generated = "false"
+	}
 	rows, err := db.Query(ctx, `
 		SELECT
 			column_name,
-			CASE WHEN data_type='USER-DEFINED' THEN udt_name ELSE data_type END,
-			col_description(format('%I.%I', table_schema, table_name)::regclass::oid, ordinal_position)
+			CASE
+				WHEN data_type='USER-DEFINED' THEN udt_name
+				WHEN data_type='ARRAY' THEN udt_name::regtype::text
+				WHEN data_type='numeric' AND numeric_precision IS NOT NULL
+					THEN format('numeric(%s,%s)', numeric_precision, numeric_scale)
+				ELSE data_type
+			END,
+			col_description(format('%I.%I', table_schema, table_name)::regclass::oid, ordinal_position),
+			`+generated+`
 		FROM information_schema.columns
 		WHERE table_schema = $1 and table_name = $2`, tm.Schema, name)
 	if err != nil {
@@ -290,8 +692,9 @@ func (tm *TableManager) getColumns(ctx context.Context, db dbh, name string) (ma
 	cols := make(map[string]utils.Column)
 	for rows.Next() {
 		var colName, colType string
+		var generated bool
 		desc := new(string)
-		err := rows.Scan(&colName, &colType, &desc)
+		err := rows.Scan(&colName, &colType, &desc, &generated)
 		if err != nil {
 			return nil, err
 		}
@@ -316,36 +719,184 @@ func (tm *TableManager) getColumns(ctx context.Context, db dbh, name string) (ma
 			}
 		}
 
+		cols[colName] = utils.Column{
+			Name:      colName,
+			Type:      colType,
+			Role:      role,
+			Generated: generated,
+		}
+	}
+
+	return cols, rows.Err()
+}
+
+// addToColumnarEngine adds a newly created table, or newly added columns, to the AlloyDB columnar engine. As this is
+// only an optimization, failure is logged rather than failing the write.
+func (tm *TableManager) addToColumnarEngine(ctx context.Context, tx dbh, table string, created bool, cols []utils.Column) {
+	sql := "SELECT google_columnar_engine_add(" + sqltemplate.QuoteLiteral(table) + ")"
+	if !created {
+		colNames := make([]string, len(cols))
+		for i, col := range cols {
+			colNames[i] = col.Name
+		}
+		sql = "SELECT google_columnar_engine_add(relation => " + sqltemplate.QuoteLiteral(table) +
+			", columns => " + sqltemplate.QuoteLiteral(strings.Join(colNames, ",")) + ")"
+	}
+	if tm.DDLDryRun {
+		_, _ = tx.Exec(ctx, sql)
+		return
+	}
+
+	// Executed within a savepoint, so that an error does not abort the enclosing transaction.
+	sp, err := tx.Begin(ctx)
+	if err == nil {
+		if _, err = sp.Exec(ctx, sql); err == nil {
+			err = sp.Commit(ctx)
+		}
+		if err != nil {
+			_ = sp.Rollback(ctx)
+		}
+	}
+	if err != nil {
+		tm.Logger.Warnf("Couldn't add %s to the columnar engine: %v", table, err)
+	}
+}
+
+// showColumns is the equivalent of getColumns for databases without information_schema. As such databases don't
+// support column comments either, tag columns are identified by their type.
+func (tm *TableManager) showColumns(ctx context.Context, db dbh, name string) (map[string]utils.Column, error) {
+	rows, err := db.Query(ctx, "SHOW COLUMNS FROM "+utils.QuoteIdentifier(name))
+	if err != nil {
+		return showColumnsError(err)
+	}
+	defer rows.Close()
+
+	cols := make(map[string]utils.Column)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) < 2 {
+			return nil, fmt.Errorf("unexpected SHOW COLUMNS result for %q", name)
+		}
+		colName := fmt.Sprint(values[0])
+		colType := strings.ToLower(fmt.Sprint(values[1]))
+
+		role := utils.FieldColType
+		switch {
+		case colName == timeColumnName:
+			role = utils.TimeColType
+		case colName == tagsJSONColumnName, colType == tm.dialect.tagType:
+			role = utils.TagColType
+		}
+		if pgType, ok := tm.dialect.pgTypes[colType]; ok {
+			colType = pgType
+		}
+
 		cols[colName] = utils.Column{
 			Name: colName,
 			Type: colType,
 			Role: role,
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return showColumnsError(err)
+	}
 
-	return cols, rows.Err()
+	return cols, nil
 }
 
-//nolint:revive
-func (tm *TableManager) update(ctx context.Context,
-	tx pgx.Tx,
+// showColumnsError returns the result of showColumns for the error of SHOW COLUMNS: no columns if the table doesn't
+// exist, and the error otherwise.
+func showColumnsError(err error) (map[string]utils.Column, error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" { // undefined_table
+		return map[string]utils.Column{}, nil
+	}
+	return nil, err
+}
+
+// templateTables returns the template objects of the table being modified, its metric table, and its tag table. The
+// tag table is nil without tags_as_foreign_keys.
+func (tm *TableManager) templateTables(
 	state *tableState,
-	tmpls []*sqltemplate.Template,
-	missingCols []utils.Column,
 	metricsTable *tableState,
 	tagsTable *tableState,
-) error {
-	tmplTable := sqltemplate.NewTable(tm.Schema, state.name, colMapToSlice(state.columns))
-	metricsTmplTable := sqltemplate.NewTable(tm.Schema, metricsTable.name, colMapToSlice(metricsTable.columns))
+) (*sqltemplate.Table, *sqltemplate.Table, *sqltemplate.Table) {
+	tmplTable := sqltemplate.NewTable(tm.Schema, state.name, tm.translateColumns(colMapToSlice(state.columns)))
+	metricsTmplTable := sqltemplate.NewTable(tm.Schema, metricsTable.name,
+		tm.translateColumns(colMapToSlice(metricsTable.columns)))
 	var tagsTmplTable *sqltemplate.Table
 	if tagsTable != nil {
-		tagsTmplTable = sqltemplate.NewTable(tm.Schema, tagsTable.name, colMapToSlice(tagsTable.columns))
+		tagsTmplTable = sqltemplate.NewTable(tm.Schema, tagsTable.name, tm.translateColumns(colMapToSlice(tagsTable.columns)))
 	} else {
 		tagsTmplTable = sqltemplate.NewTable("", "", nil)
 	}
+	return tmplTable, metricsTmplTable, tagsTmplTable
+}
+
+// postCreate executes the post_create_templates (or tag_table_post_create_templates) of a newly created table. Each
+// statement is executed in its own transaction (or savepoint, when db is the transaction of a write), and failures are
+// only logged, so that they don't prevent metrics being written to the table.
+func (tm *TableManager) postCreate(
+	ctx context.Context,
+	db dbh,
+	tbl *tableState,
+	metricsTable *tableState,
+	tagsTable *tableState,
+	metric *sqltemplate.Metric,
+) {
+	tmpls := tm.tableTemplates(metricsTable.name).PostCreateTemplates
+	if tbl == tagsTable {
+		tmpls = tm.tableTemplates(metricsTable.name).TagTablePostCreateTemplates
+	}
+	if len(tmpls) == 0 {
+		return
+	}
 
+	tmplTable, metricsTmplTable, tagsTmplTable := tm.templateTables(tbl, metricsTable, tagsTable)
+	columns := tm.translateColumns(colMapToSlice(tbl.columns))
 	for _, tmpl := range tmpls {
-		sql, err := tmpl.Render(tmplTable, missingCols, metricsTmplTable, tagsTmplTable)
+		sql, err := tmpl.Render(tmplTable, columns, metricsTmplTable, tagsTmplTable, tm.Tablespace, tm.ColumnOrder, metric)
+		if err != nil {
+			tm.Logger.Errorf("rendering post-create template for table %s: %v", tbl.name, err)
+			continue
+		}
+		if err := tm.execPostCreate(ctx, db, string(sql)); err != nil {
+			tm.Logger.Errorf("executing post-create statement `%s` for table %s: %v", sql, tbl.name, err)
+		}
+	}
+}
+
+// execPostCreate executes a post-create statement in its own transaction.
+func (tm *TableManager) execPostCreate(ctx context.Context, db dbh, sql string) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+	if _, err := tm.ddlHandle(tx).Exec(ctx, sql); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+//nolint:revive
+func (tm *TableManager) update(ctx context.Context,
+	tx dbh,
+	state *tableState,
+	tmpls []*sqltemplate.Template,
+	missingCols []utils.Column,
+	metricsTable *tableState,
+	tagsTable *tableState,
+	metric *sqltemplate.Metric,
+) error {
+	tmplTable, metricsTmplTable, tagsTmplTable := tm.templateTables(state, metricsTable, tagsTable)
+
+	for _, tmpl := range tmpls {
+		sql, err := tmpl.Render(tmplTable, tm.translateColumns(missingCols), metricsTmplTable, tagsTmplTable, tm.Tablespace,
+			tm.ColumnOrder, metric)
 		if err != nil {
 			return err
 		}
@@ -354,16 +905,49 @@ func (tm *TableManager) update(ctx context.Context,
 		}
 	}
 
+	if tm.columnarEngine && state == metricsTable && len(missingCols) > 0 {
+		tm.addToColumnarEngine(ctx, tx, tmplTable.String(), len(state.columns) == 0, missingCols)
+	}
+
+	if tm.dialect.noInformationSchema {
+		// Without column comments, the role of a column is determined by its type.
+		return nil
+	}
+
+	if tm.MetadataComments && len(state.columns) == 0 {
+		desc := fmt.Sprintf("measurement %q", metricsTable.name)
+		if state != metricsTable {
+			desc = "tags of " + desc
+		}
+		stmt := fmt.Sprintf("COMMENT ON TABLE %s IS %s", tmplTable.String(), sqltemplate.QuoteLiteral(desc))
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("setting table comment: %s", err)
+		}
+	}
+
 	// We need to be able to determine the role of the column when reading the structure back (because of the templates).
 	// For some columns we can determine this by the column name (time, tag_id, etc). However tags and fields can have any
 	// name, and look the same. So we add a comment to tag columns, and through process of elimination what remains are
 	// field columns.
+	// Only the first word of the comment is used to determine the role, so metadata may follow it.
 	for _, col := range missingCols {
-		if col.Role != utils.TagColType {
+		var desc string
+		switch col.Role {
+		case utils.TagColType:
+			desc = "tag"
+		case utils.FieldColType:
+			if !tm.MetadataComments {
+				continue
+			}
+			desc = "field"
+		default:
 			continue
 		}
-		stmt := fmt.Sprintf("COMMENT ON COLUMN %s.%s IS 'tag'",
-			tmplTable.String(), sqltemplate.QuoteIdentifier(col.Name))
+		if tm.MetadataComments {
+			desc += fmt.Sprintf(" %q (%s) of measurement %q", col.Name, telegrafDatatype(col.Type), metricsTable.name)
+		}
+		stmt := fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s",
+			tmplTable.String(), utils.QuoteIdentifier(col.Name), sqltemplate.QuoteLiteral(desc))
 		if _, err := tx.Exec(ctx, stmt); err != nil {
 			return fmt.Errorf("setting column role comment: %s", err)
 		}
@@ -372,6 +956,23 @@ func (tm *TableManager) update(ctx context.Context,
 	return nil
 }
 
+// hasColumns reports whether the cached structure of the table has all of the columns, and none of them are generated,
+// such that values can be written to the columns without first matching the table structure.
+func (tm *TableManager) hasColumns(name string, columns []utils.Column) bool {
+	tbl := tm.table(name)
+	tbl.RLock()
+	defer tbl.RUnlock()
+	if len(tbl.columns) == 0 {
+		return false
+	}
+	for _, col := range columns {
+		if tblCol, ok := tbl.columns[col.Name]; !ok || tblCol.Generated {
+			return false
+		}
+	}
+	return true
+}
+
 // diffMissingColumns filters srcColumns to the ones not present in dbColumns.
 func diffMissingColumns(dbColumns map[string]utils.Column, srcColumns []utils.Column) []utils.Column {
 	if len(dbColumns) == 0 {
@@ -388,6 +989,35 @@ func diffMissingColumns(dbColumns map[string]utils.Column, srcColumns []utils.Co
 	return missingColumns
 }
 
+// diffNarrowColumns returns the field columns from srcColumns whose type is wider than the matching column in dbColumns.
+// The returned columns hold the type the DB column must be widened to.
+func diffNarrowColumns(dbColumns map[string]utils.Column, srcColumns []utils.Column) []utils.Column {
+	var narrowColumns []utils.Column
+	for _, srcCol := range srcColumns {
+		dbCol, ok := dbColumns[srcCol.Name]
+		if !ok || dbCol.Role != utils.FieldColType || dbCol.Generated {
+			continue
+		}
+		if newType := widenPgDatatype(dbCol.Type, srcCol.Type); newType != "" {
+			dbCol.Type = newType
+			narrowColumns = append(narrowColumns, dbCol)
+		}
+	}
+	return narrowColumns
+}
+
+// mergeColumns returns a copy of the column map, with the given columns added or replacing those of the same name.
+func mergeColumns(colMap map[string]utils.Column, cols []utils.Column) map[string]utils.Column {
+	merged := make(map[string]utils.Column, len(colMap)+len(cols))
+	for name, col := range colMap {
+		merged[name] = col
+	}
+	for _, col := range cols {
+		merged[col.Name] = col
+	}
+	return merged
+}
+
 func colMapToSlice(colMap map[string]utils.Column) []utils.Column {
 	if colMap == nil {
 		return nil
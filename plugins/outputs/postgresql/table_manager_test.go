@@ -1,13 +1,17 @@
 package postgresql
 
 import (
+	"math"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
 	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
 )
@@ -29,6 +33,7 @@ func TestTableManager_EnsureStructure(t *testing.T) {
 		p.AddColumnTemplates,
 		p.tableManager.table(t.Name()),
 		nil,
+		nil,
 	)
 	require.NoError(t, err)
 	assert.Empty(t, missingCols)
@@ -55,6 +60,7 @@ func TestTableManager_EnsureStructure_alter(t *testing.T) {
 		p.AddColumnTemplates,
 		p.tableManager.table(t.Name()),
 		nil,
+		nil,
 	)
 	require.NoError(t, err)
 
@@ -68,6 +74,7 @@ func TestTableManager_EnsureStructure_alter(t *testing.T) {
 		p.AddColumnTemplates,
 		p.tableManager.table(t.Name()),
 		nil,
+		nil,
 	)
 	require.NoError(t, err)
 	assert.Empty(t, missingCols)
@@ -95,6 +102,7 @@ func TestTableManager_getColumns(t *testing.T) {
 		p.AddColumnTemplates,
 		p.tableManager.table(t.Name()),
 		nil,
+		nil,
 	)
 	require.NoError(t, err)
 
@@ -339,3 +347,610 @@ func TestTableManager_addColumnTemplates(t *testing.T) {
 	assert.Contains(t, log, `metricTable:"public"."TestTableManager_addColumnTemplates"`)
 	assert.Contains(t, log, `tagTable:"public"."TestTableManager_addColumnTemplates_tag"`)
 }
+
+func TestTableManager_createIndexTemplates(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(`CREATE INDEX ON {{.table}} USING BRIN (time)`)))
+	p.CreateIndexTemplates = []*sqltemplate.Template{tmpl}
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	var indexDef string
+	row := p.db.QueryRow(ctx, "SELECT indexdef FROM pg_indexes WHERE schemaname = $1 AND tablename = $2", p.Schema, t.Name())
+	require.NoError(t, row.Scan(&indexDef))
+	assert.Contains(t, indexDef, "USING brin")
+}
+
+func TestTableManager_postCreateTemplates(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	tmplBad := &sqltemplate.Template{}
+	require.NoError(t, tmplBad.UnmarshalText([]byte(`GRANT SELECT ON {{.table}} TO no_such_role`)))
+	tmplComment := &sqltemplate.Template{}
+	require.NoError(t, tmplComment.UnmarshalText([]byte(`COMMENT ON TABLE {{.table}} IS 'post-create'`)))
+	p.PostCreateTemplates = []*sqltemplate.Template{tmplBad, tmplComment}
+	p.TagTablePostCreateTemplates = []*sqltemplate.Template{tmplComment}
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+
+	// The failing statement doesn't prevent the metrics being written, or the statements after it.
+	require.Len(t, dbTableDump(t, p.db, ""), 1)
+	assert.True(t, p.Logger.HasLevel(pgx.LogLevelError))
+	for _, suffix := range []string{"", p.TagTableSuffix} {
+		var comment string
+		row := p.db.QueryRow(ctx, "SELECT obj_description($1::regclass)",
+			utils.FullTableName(p.Schema, t.Name()+suffix).Sanitize())
+		require.NoError(t, row.Scan(&comment))
+		assert.Equal(t, "post-create", comment)
+	}
+}
+
+func TestTableManager_metadataComments(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	p.MetadataComments = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1.5}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	var tblDesc, tagTblDesc string
+	row := p.db.QueryRow(ctx, "SELECT obj_description($1::regclass), obj_description($2::regclass)",
+		utils.QuoteIdentifier(t.Name()), utils.QuoteIdentifier(t.Name()+p.TagTableSuffix))
+	require.NoError(t, row.Scan(&tblDesc, &tagTblDesc))
+	assert.Equal(t, `measurement "`+t.Name()+`"`, tblDesc)
+	assert.Equal(t, `tags of measurement "`+t.Name()+`"`, tagTblDesc)
+
+	// Verify that the column roles can still be read back from the comments.
+	p.tableManager.ClearTableCache()
+	tagCols, err := p.tableManager.getColumns(ctx, p.db, t.Name()+p.TagTableSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, utils.TagColType, tagCols["tag"].Role)
+	cols, err := p.tableManager.getColumns(ctx, p.db, t.Name())
+	require.NoError(t, err)
+	assert.Equal(t, utils.FieldColType, cols["a"].Role)
+
+	var colDesc string
+	row = p.db.QueryRow(ctx, "SELECT col_description($1::regclass, 2)", utils.QuoteIdentifier(t.Name()+p.TagTableSuffix))
+	require.NoError(t, row.Scan(&colDesc))
+	assert.Equal(t, `tag "tag" (string) of measurement "`+t.Name()+`"`, colDesc)
+}
+
+// Verify that generated columns declared by the create templates are excluded from the written columns.
+func TestTableManager_generatedColumns(t *testing.T) {
+	p := newPostgresqlTest(t)
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(`CREATE TABLE {{.table}} ({{.columns}}, "b" bigint GENERATED ALWAYS AS ("a" * 2) STORED)`)))
+	p.CreateTemplates = []*sqltemplate.Template{tmpl}
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.True(t, p.tableManager.table(t.Name()).columns["b"].Generated)
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 2, "b": 5}),
+	}
+	tsrc = NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Contains(t, tsrc.ColumnNames(), "a")
+	assert.NotContains(t, tsrc.ColumnNames(), "b")
+}
+
+func TestTableManager_widenColumnTemplates(t *testing.T) {
+	p := newPostgresqlTest(t)
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(
+		`ALTER TABLE {{.table}} {{range $i, $c := .columns}}{{if $i}}, {{end}}ALTER COLUMN {{$c.Identifier}} TYPE {{$c.Type}}{{end}}`,
+	)))
+	p.WidenColumnTemplates = []*sqltemplate.Template{tmpl}
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1, "b": 1.5}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 2.5, "b": 2}),
+	}
+	tsrc = NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, PgDoublePrecision, p.tableManager.table(t.Name()).columns["a"].Type)
+	// integer values fit in a float column, so it must not be narrowed
+	assert.Equal(t, PgDoublePrecision, p.tableManager.table(t.Name()).columns["b"].Type)
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": "three"}),
+	}
+	tsrc = NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, PgText, p.tableManager.table(t.Name()).columns["a"].Type)
+}
+
+func TestTableManager_MatchSource_citext(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.UseCitext = true
+	p.TagsAsForeignKeys = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, PgCitext, p.tableManager.table(t.Name()+p.TagTableSuffix).columns["tag"].Type)
+}
+
+func TestTableManager_MatchSource_uint64Type(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.Uint64Type = PgNumeric20
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"a": uint64(math.MaxUint64)}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, PgNumeric20, p.tableManager.table(t.Name()).columns["a"].Type)
+
+	var precision int
+	row := p.db.QueryRow(ctx, "SELECT numeric_precision FROM information_schema.columns WHERE table_schema=$1 AND table_name=$2 AND column_name='a'",
+		p.Schema, t.Name())
+	require.NoError(t, row.Scan(&precision))
+	assert.Equal(t, 20, precision)
+
+	require.NoError(t, p.writeMetricsFromMeasure(ctx, p.db, NewTableSources(p.Postgresql, metrics)[t.Name()]))
+}
+
+func TestTableManager_MatchSource_timestampWithTimezone(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TimestampWithTimezone = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, PgTimestampWithTimeZone, p.tableManager.table(t.Name()).columns[timeColumnName].Type)
+}
+
+func TestTableManager_MatchSource_timePrecision(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TimePrecision = "ms"
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	var precision int
+	row := p.db.QueryRow(ctx, "SELECT datetime_precision FROM information_schema.columns WHERE table_schema=$1 AND table_name=$2 AND column_name='time'",
+		p.Schema, t.Name())
+	require.NoError(t, row.Scan(&precision))
+	assert.Equal(t, 3, precision)
+}
+
+func TestTableManager_MatchSource_epochTime(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TimeFormat = "unix_ms"
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, PgBigInt, p.tableManager.table(t.Name()).columns[timeColumnName].Type)
+}
+
+func TestTableManager_MatchSource_enumTags(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.EnumTags = []string{t.Name() + "_tag"}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+	tagKey := t.Name() + "_tag"
+	defer p.db.Exec(ctx, "DROP TYPE IF EXISTS "+utils.FullTableName(p.Schema, enumTypeName(tagKey)).Sanitize()+" CASCADE") //nolint:errcheck
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{tagKey: "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	require.NoError(t, p.writeMetricsFromMeasure(ctx, p.db, tsrc))
+	assert.Equal(t, enumTypeName(tagKey), p.tableManager.table(t.Name()).columns[tagKey].Type)
+
+	// A new value is added to the type.
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{tagKey: "bar"}, MSI{"a": 2}),
+	}
+	tsrc = NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	require.NoError(t, p.writeMetricsFromMeasure(ctx, p.db, tsrc))
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 2)
+}
+
+func TestTableManager_tablespace(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.Tablespace = "pg_default"
+	require.NoError(t, p.Init())
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(`-- tablespace: {{ .tablespace }}`)))
+	p.CreateTemplates = append(p.CreateTemplates, tmpl)
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	var log string
+	for _, l := range p.Logger.Logs() {
+		if strings.Contains(l.String(), "-- tablespace") {
+			log = l.String()
+			break
+		}
+	}
+	assert.Contains(t, log, `-- tablespace: "pg_default"`)
+}
+
+func TestTableManager_sampleMetric(t *testing.T) {
+	p := newPostgresqlTest(t)
+	require.NoError(t, p.Init())
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(`-- region: {{ .metric.Tags.region }} a: {{ .metric.Types.a }}`)))
+	p.CreateTemplates = append(p.CreateTemplates, tmpl)
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"region": "eu"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	var log string
+	for _, l := range p.Logger.Logs() {
+		if strings.Contains(l.String(), "-- region") {
+			log = l.String()
+			break
+		}
+	}
+	assert.Contains(t, log, `-- region: eu a: bigint`)
+}
+
+func TestTableManager_templateMetric(t *testing.T) {
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(
+		`{{ .metric.Tags.region }} {{ .metric.Tag "zone" }} {{ .metric.Field "a" }} {{ .metric.Types.a }}`)))
+	table := sqltemplate.NewTable("public", "cpu", nil)
+	metric := &sqltemplate.Metric{
+		Name:   "cpu",
+		Tags:   map[string]string{"region": "eu"},
+		Fields: map[string]interface{}{"a": int64(1)},
+		Types:  map[string]string{"a": "bigint"},
+	}
+	out, err := tmpl.Render(table, nil, table, nil, "", nil, metric)
+	require.NoError(t, err)
+	assert.Equal(t, "eu  1 bigint", string(out))
+
+	// Tag and Field return empty values for missing keys, rather than an error.
+	require.NoError(t, tmpl.UnmarshalText([]byte(`{{ .metric.Tag "region" }}{{ if .metric.Field "a" }}a{{ end }}`)))
+	out, err = tmpl.Render(table, nil, table, nil, "", nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, string(out))
+}
+
+func TestTableManager_templateSprig(t *testing.T) {
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(
+		`{{ $intervals := dict "cpu" "1h" "disk" "1d" }}`+
+			`{{ index $intervals .table.Name | default "1w" }}`+
+			`{{ if has .table.Name (list "cpu" "mem") }} tracked{{ end }}`+
+			` {{ .table.Name | trimSuffix "_total" | replace "_" "-" }}`+
+			` {{ splitList "," "b,a,b" | uniq | sortAlpha | join "," }}`)))
+
+	out, err := tmpl.Render(sqltemplate.NewTable("public", "cpu", nil), nil, nil, nil, "", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1h tracked cpu a,b", string(out))
+
+	out, err = tmpl.Render(sqltemplate.NewTable("public", "net_io_total", nil), nil, nil, nil, "", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1w net-io a,b", string(out))
+}
+
+func TestTableManager_templateFuncs(t *testing.T) {
+	t.Setenv("PG_TEST_TABLESPACE", "")
+	tmpl := &sqltemplate.Template{}
+	require.NoError(t, tmpl.UnmarshalText([]byte(
+		`CREATE TABLE {{ .table.Name | regexReplace "_[0-9]+$" "" | upper | quoteIdentifier }} ()`+
+			` TABLESPACE {{ envDefault "PG_TEST_TABLESPACE" "pg_default" }}`+
+			`{{ if regexMatch "^cpu" .table.Name }} -- cpu{{ end }}`+
+			`{{ .tablespace | default " -- none" }}`)))
+	table := sqltemplate.NewTable("public", "cpu_2021", nil)
+	out, err := tmpl.Render(table, nil, table, nil, "", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE "CPU" () TABLESPACE pg_default -- cpu -- none`, string(out))
+
+	t.Setenv("PG_TEST_TABLESPACE", "fast")
+	out, err = tmpl.Render(table, nil, table, nil, "", nil, nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "TABLESPACE fast")
+
+	require.NoError(t, tmpl.UnmarshalText([]byte(`{{ .table | regexReplace "(" "" }}`)))
+	_, err = tmpl.Render(table, nil, table, nil, "", nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "regexReplace")
+}
+
+func TestTableManager_createViews(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	p.CreateViews = true
+	require.NoError(t, p.Connect())
+
+	viewColumns := func() []string {
+		rows, err := p.db.Query(ctx,
+			"SELECT column_name FROM information_schema.columns WHERE table_schema = $1 AND table_name = $2 ORDER BY ordinal_position",
+			p.Schema, t.Name()+p.ViewSuffix)
+		require.NoError(t, err)
+		defer rows.Close()
+		var cols []string
+		for rows.Next() {
+			var col string
+			require.NoError(t, rows.Scan(&col))
+			cols = append(cols, col)
+		}
+		require.NoError(t, rows.Err())
+		return cols
+	}
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, []string{"time", "tag", "a"}, viewColumns())
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo", "atag": "bar"}, MSI{"a": 1, "b": 2}),
+	}
+	tsrc = NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, []string{"time", "atag", "tag", "a", "b"}, viewColumns())
+}
+
+func TestTableManager_noDDL_dropMetrics(t *testing.T) {
+	p := newPostgresqlTest(t)
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	p2 := newPostgresqlTest(t)
+	p2.NoDDL = true
+	p2.SchemaMismatchPolicy = "drop_metrics"
+	require.NoError(t, p2.Init())
+	require.NoError(t, p2.Connect())
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 2}),
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 3, "b": 3}),
+	}
+	tsrc = NewTableSources(p2.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p2.tableManager.MatchSource(ctx, p2.db, tsrc))
+	assert.NotContains(t, tsrc.ColumnNames(), "b")
+	var rows int
+	for tsrc.Next() {
+		rows++
+	}
+	assert.Equal(t, 1, rows)
+
+	// table doesn't exist
+	metrics = []telegraf.Metric{
+		newMetric(t, "_missing", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc = NewTableSources(p2.Postgresql, metrics)[t.Name()+"_missing"]
+	require.NoError(t, p2.tableManager.MatchSource(ctx, p2.db, tsrc))
+	assert.False(t, tsrc.Next())
+}
+
+func TestTableManager_schemaMismatchPolicyError(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.AddColumnTemplates = []*sqltemplate.Template{}
+	p.SchemaMismatchPolicy = "error"
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 2, "b": 2}),
+	}
+	tsrc = NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.Error(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+}
+
+func TestTableManager_ddlDryRun(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.DDLDryRun = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Contains(t, tsrc.ColumnNames(), "a")
+
+	var logged bool
+	for _, l := range p.Logger.Logs() {
+		if strings.Contains(l.String(), "DDL (dry run): CREATE TABLE") {
+			logged = true
+			break
+		}
+	}
+	assert.True(t, logged)
+
+	cols, err := p.tableManager.getColumns(ctx, p.db, t.Name())
+	require.NoError(t, err)
+	assert.Empty(t, cols)
+}
+
+func TestTableManager_columnOrder(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.ColumnOrder = []string{"time", "b", "tag"}
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo", "atag": "bar"}, MSI{"a": 1, "b": 2}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	rows, err := p.db.Query(ctx,
+		"SELECT column_name FROM information_schema.columns WHERE table_schema = $1 AND table_name = $2 ORDER BY ordinal_position",
+		p.Schema, t.Name())
+	require.NoError(t, err)
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var col string
+		require.NoError(t, rows.Scan(&col))
+		cols = append(cols, col)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"time", "b", "tag", "atag", "a"}, cols)
+}
+
+func TestTableManager_typeConflictColumns(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TypeConflictColumns = true
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	p.tableManager.ClearTableCache()
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": "bar"}),
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 2}),
+	}
+	tsrc = NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Equal(t, PgBigInt, p.tableManager.table(t.Name()).columns["a"].Type)
+	assert.Equal(t, PgText, p.tableManager.table(t.Name()).columns["a__string"].Type)
+
+	require.True(t, tsrc.Next())
+	values, err := tsrc.Values()
+	require.NoError(t, err)
+	assert.Contains(t, values, "bar")
+	assert.NotContains(t, values, int64(2))
+	require.True(t, tsrc.Next())
+	values, err = tsrc.Values()
+	require.NoError(t, err)
+	assert.Contains(t, values, int64(2))
+	assert.NotContains(t, values, "bar")
+}
+
+func TestTableManager_tableCacheTTL(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TableCacheTTL = config.Duration(time.Millisecond)
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+
+	_, err := p.db.Exec(ctx, "ALTER TABLE "+utils.FullTableName(p.Schema, t.Name()).Sanitize()+" ADD COLUMN b text")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 10)
+	tbl := p.tableManager.table(t.Name())
+	assert.Nil(t, tbl.columns)
+
+	tsrc = NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Contains(t, tbl.columns, "b")
+}
+
+func TestTableManager_tagTablePartitions(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	p.TagTablePartitions = 4
+	p.TagTableCreateTemplates = nil
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, p.db, tsrc))
+	assert.Contains(t, p.tableManager.table(t.Name()+p.TagTableSuffix).columns, "tag")
+
+	var partitions int
+	row := p.db.QueryRow(ctx, "SELECT count(*) FROM pg_inherits WHERE inhparent = $1::regclass",
+		utils.FullTableName(p.Schema, t.Name()+p.TagTableSuffix).Sanitize())
+	require.NoError(t, row.Scan(&partitions))
+	assert.Equal(t, 4, partitions)
+}
+
+func TestTableManager_MatchSource_ddlLockTimeoutReset(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.DDLLockTimeout = config.Duration(100 * time.Millisecond)
+	require.NoError(t, p.Connect())
+
+	// As when writing sequentially, the table is created within the transaction the metrics are written in.
+	tx, err := p.db.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx) //nolint:errcheck
+	var before string
+	require.NoError(t, tx.QueryRow(ctx, "SHOW lock_timeout").Scan(&before))
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"a": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	require.NoError(t, p.tableManager.MatchSource(ctx, tx, tsrc))
+	require.Contains(t, p.tableManager.table(t.Name()).columns, "a")
+
+	var after string
+	require.NoError(t, tx.QueryRow(ctx, "SHOW lock_timeout").Scan(&after))
+	assert.Equal(t, before, after)
+}
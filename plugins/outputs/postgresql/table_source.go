@@ -3,8 +3,11 @@ package postgresql
 import (
 	"fmt"
 	"hash/fnv"
+	"sort"
+	"sync"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
 	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
 )
 
@@ -43,6 +46,39 @@ func (cl *columnList) Remove(name string) bool {
 	return true
 }
 
+// fieldColumnKey identifies the values of a field which are of a specific data type.
+type fieldColumnKey struct {
+	key    string
+	pgType string
+}
+
+// rowPool holds the slices which TableSource and TagTableSource build rows in, so that they are reused across rows
+// and writes rather than allocated for each row.
+var rowPool = sync.Pool{
+	New: func() interface{} {
+		return new([]interface{})
+	},
+}
+
+// putRow clears the row, so that the values it referenced can be garbage collected, and returns it to rowPool.
+func putRow(row *[]interface{}) {
+	values := (*row)[:cap(*row)]
+	for i := range values {
+		values[i] = nil
+	}
+	*row = values[:0]
+	rowPool.Put(row)
+}
+
+// appendNils appends n nil values to the row. It returns the row, and the appended values, which may be set in place.
+func appendNils(row []interface{}, n int) ([]interface{}, []interface{}) {
+	start := len(row)
+	for i := 0; i < n; i++ {
+		row = append(row, nil)
+	}
+	return row, row[start:]
+}
+
 // TableSource satisfies pgx.CopyFromSource
 type TableSource struct {
 	postgresql   *Postgresql
@@ -50,6 +86,10 @@ type TableSource struct {
 	cursor       int
 	cursorValues []interface{}
 	cursorError  error
+	// cursorColumnDropped is set when the metric at the cursor is skipped due to containing a dropped column.
+	cursorColumnDropped bool
+	// row is the buffer from rowPool which rows are built in, while iterating.
+	row *[]interface{}
 	// tagHashSalt is so that we can use a global tag cache for all tables. The salt is unique per table, and combined
 	// with the tag ID when looked up in the cache.
 	tagHashSalt int64
@@ -58,11 +98,29 @@ type TableSource struct {
 	// tagSets is the list of tag IDs to tag values in use within the TableSource. The position of each value in the list
 	// corresponds to the key name in the tagColumns list.
 	// This data is used to build out the foreign tag table when enabled.
-	tagSets map[int64][]*telegraf.Tag
+	tagSets map[tagID][]*telegraf.Tag
 
 	fieldColumns *columnList
+	// fieldColumnNames maps each field key & value data type to the name of the column the value is written to. Only
+	// used with type_conflict_columns.
+	fieldColumnNames map[fieldColumnKey]string
+	// fieldColumnTypes holds the data types of the table's field columns which differ from those of the values written
+	// to them, such as after the column was widened, so that the values can be converted.
+	fieldColumnTypes map[string]string
 
 	droppedTagColumns []string
+	// droppedFieldMetrics are the fields for which any metric containing them is skipped.
+	droppedFieldMetrics map[string]bool
+	// rawColumnDropped is set when the raw_column column is dropped, as the table lacks it.
+	rawColumnDropped bool
+	// metricsDropped is set when none of the metrics can be emitted, such as when the table does not exist.
+	metricsDropped bool
+	// tagsWritten is set when the tags have been written to the tag table along with those of other table sources, by
+	// writeTagTables.
+	tagsWritten bool
+
+	tagsAsJsonb   bool
+	fieldsAsJsonb bool
 }
 
 func NewTableSources(p *Postgresql, metrics []telegraf.Metric) map[string]*TableSource {
@@ -81,47 +139,143 @@ func NewTableSources(p *Postgresql, metrics []telegraf.Metric) map[string]*Table
 }
 
 func NewTableSource(postgresql *Postgresql, name string) *TableSource {
-	h := fnv.New64a()
-	_, _ = h.Write([]byte(name))
-
 	tsrc := &TableSource{
-		postgresql:  postgresql,
-		cursor:      -1,
-		tagSets:     make(map[int64][]*telegraf.Tag),
-		tagHashSalt: int64(h.Sum64()),
-	}
-	if !postgresql.TagsAsJsonb {
-		tsrc.tagColumns = newColumnList()
-	}
-	if !postgresql.FieldsAsJsonb {
+		postgresql:    postgresql,
+		cursor:        -1,
+		tagSets:       make(map[tagID][]*telegraf.Tag),
+		tagHashSalt:   tagHashSalt(name),
+		tagsAsJsonb:   postgresql.tagsAsJsonb(name),
+		fieldsAsJsonb: postgresql.fieldsAsJsonb(name),
+		tagColumns:    newColumnList(),
+	}
+	if !tsrc.fieldsAsJsonb {
 		tsrc.fieldColumns = newColumnList()
+		if postgresql.TypeConflictColumns {
+			tsrc.fieldColumnNames = make(map[fieldColumnKey]string)
+		}
 	}
 	return tsrc
 }
 
 func (tsrc *TableSource) AddMetric(metric telegraf.Metric) {
 	if tsrc.postgresql.TagsAsForeignKeys {
-		tagID := utils.GetTagID(metric)
+		tagID := tsrc.postgresql.tagID(metric)
 		if _, ok := tsrc.tagSets[tagID]; !ok {
 			tsrc.tagSets[tagID] = metric.TagList()
 		}
 	}
 
-	if !tsrc.postgresql.TagsAsJsonb {
-		for _, t := range metric.TagList() {
+	for _, t := range metric.TagList() {
+		if tsrc.isTagColumn(t.Key) {
 			tsrc.tagColumns.Add(tsrc.postgresql.columnFromTag(t.Key, t.Value))
 		}
 	}
 
-	if !tsrc.postgresql.FieldsAsJsonb {
+	if !tsrc.fieldsAsJsonb {
 		for _, f := range metric.FieldList() {
-			tsrc.fieldColumns.Add(tsrc.postgresql.columnFromField(f.Key, f.Value))
+			value, ok := tsrc.postgresql.fieldValue(f.Key, f.Value)
+			if !ok {
+				continue
+			}
+			if value == nil {
+				value = f.Value
+			}
+			col := tsrc.postgresql.columnFromField(f.Key, value)
+			if tsrc.fieldColumnNames != nil {
+				col.Name = tsrc.fieldColumnName(col)
+			}
+			tsrc.fieldColumns.Add(col)
+		}
+		for _, gp := range tsrc.postgresql.geometryPoints {
+			if _, ok := gp.value(metric); ok {
+				col := gp.columnDef()
+				if tsrc.fieldColumnNames != nil {
+					col.Name = tsrc.fieldColumnName(col)
+				}
+				tsrc.fieldColumns.Add(col)
+			}
 		}
 	}
 
 	tsrc.metrics = append(tsrc.metrics, metric)
 }
 
+// fieldColumnName returns the name of the column which values of the field column's key & type are written to. Values
+// of a type which the column of the same name can't hold are written to a column suffixed with the type, such as
+// "usage__float".
+func (tsrc *TableSource) fieldColumnName(col utils.Column) string {
+	fck := fieldColumnKey{key: col.Name, pgType: col.Type}
+	if name, ok := tsrc.fieldColumnNames[fck]; ok {
+		return name
+	}
+	name := col.Name
+	if idx, ok := tsrc.fieldColumns.indices[col.Name]; ok {
+		if !tsrc.postgresql.columnAccepts(tsrc.fieldColumns.columns[idx].Type, col.Type) {
+			name = typeConflictColumnName(col.Name, col.Type)
+		}
+	}
+	tsrc.fieldColumnNames[fck] = name
+	return name
+}
+
+// resolveTypeConflicts reassigns the field values to columns according to the data types of the table's existing
+// columns, as the columns were assigned when added without knowledge of the table. Returns whether any changed.
+func (tsrc *TableSource) resolveTypeConflicts(tableColumns map[string]utils.Column) bool {
+	fcks := make([]fieldColumnKey, 0, len(tsrc.fieldColumnNames))
+	for fck := range tsrc.fieldColumnNames {
+		fcks = append(fcks, fck)
+	}
+	sort.Slice(fcks, func(i, j int) bool {
+		if fcks[i].key != fcks[j].key {
+			return fcks[i].key < fcks[j].key
+		}
+		return fcks[i].pgType < fcks[j].pgType
+	})
+
+	changed := false
+	fieldColumns := newColumnList()
+	for _, fck := range fcks {
+		name := fck.key
+		if tblCol, ok := tableColumns[fck.key]; ok && !tsrc.postgresql.columnAccepts(tblCol.Type, fck.pgType) {
+			name = typeConflictColumnName(fck.key, fck.pgType)
+		}
+		if name != tsrc.fieldColumnNames[fck] {
+			tsrc.fieldColumnNames[fck] = name
+			changed = true
+		}
+		fieldColumns.Add(utils.Column{Name: name, Type: fck.pgType, Role: utils.FieldColType})
+	}
+	if changed {
+		tsrc.fieldColumns = fieldColumns
+	}
+	return changed
+}
+
+// matchFieldColumnTypes records the data types of the table's field columns which differ from those of the source's
+// field columns, so that values are converted to the type of the column they are written to.
+func (tsrc *TableSource) matchFieldColumnTypes(tableColumns map[string]utils.Column) {
+	tsrc.fieldColumnTypes = nil
+	if tsrc.fieldsAsJsonb {
+		return
+	}
+	for _, col := range tsrc.fieldColumns.columns {
+		tblCol, ok := tableColumns[col.Name]
+		if !ok || tblCol.Type == col.Type {
+			continue
+		}
+		if tsrc.fieldColumnTypes == nil {
+			tsrc.fieldColumnTypes = map[string]string{}
+		}
+		tsrc.fieldColumnTypes[col.Name] = tblCol.Type
+	}
+}
+
+// typeConflictColumnName returns the name of the column for values of the given field key which are of a type
+// conflicting with the column named after the key.
+func typeConflictColumnName(key string, pgType string) string {
+	return key + "__" + telegrafDatatype(pgType)
+}
+
 func (tsrc *TableSource) Name() string {
 	if len(tsrc.metrics) == 0 {
 		return ""
@@ -129,14 +283,21 @@ func (tsrc *TableSource) Name() string {
 	return tsrc.metrics[0].Name()
 }
 
+// isTagColumn reports whether the given tag key is stored in its own column, rather than in the tags JSONB column.
+func (tsrc *TableSource) isTagColumn(key string) bool {
+	if !tsrc.tagsAsJsonb {
+		return true
+	}
+	return tsrc.postgresql.tagColumnsFilter != nil && tsrc.postgresql.tagColumnsFilter.Match(key)
+}
+
 // Returns the superset of all tags of all metrics.
 func (tsrc *TableSource) TagColumns() []utils.Column {
 	var cols []utils.Column
 
-	if tsrc.postgresql.TagsAsJsonb {
-		cols = append(cols, tagsJSONColumn)
-	} else {
-		cols = append(cols, tsrc.tagColumns.columns...)
+	cols = append(cols, tsrc.tagColumns.columns...)
+	if tsrc.tagsAsJsonb {
+		cols = append(cols, tsrc.postgresql.tagsJSONColumn())
 	}
 
 	return cols
@@ -144,33 +305,60 @@ func (tsrc *TableSource) TagColumns() []utils.Column {
 
 // Returns the superset of all fields of all metrics.
 func (tsrc *TableSource) FieldColumns() []utils.Column {
+	if tsrc.fieldsAsJsonb {
+		return nil
+	}
 	return tsrc.fieldColumns.columns
 }
 
+// SampleMetric returns the first metric of the source, along with the types of the field columns, for use by templates.
+// Returns nil if the source has no metrics.
+func (tsrc *TableSource) SampleMetric() *sqltemplate.Metric {
+	if len(tsrc.metrics) == 0 {
+		return nil
+	}
+	metric := tsrc.metrics[0]
+
+	types := make(map[string]string)
+	for _, col := range tsrc.FieldColumns() {
+		types[col.Name] = col.Type
+	}
+	return &sqltemplate.Metric{
+		Name:   metric.Name(),
+		Tags:   metric.Tags(),
+		Fields: metric.Fields(),
+		Types:  types,
+	}
+}
+
 // Returns the full column list, including time, tag id or tags, and fields.
 func (tsrc *TableSource) MetricTableColumns() []utils.Column {
 	cols := []utils.Column{
-		timeColumn,
+		tsrc.postgresql.timeColumn(),
 	}
 
 	if tsrc.postgresql.TagsAsForeignKeys {
-		cols = append(cols, tagIDColumn)
+		cols = append(cols, tsrc.postgresql.tagIDColumn())
 	} else {
 		cols = append(cols, tsrc.TagColumns()...)
 	}
 
-	if tsrc.postgresql.FieldsAsJsonb {
-		cols = append(cols, fieldsJSONColumn)
+	if tsrc.fieldsAsJsonb {
+		cols = append(cols, tsrc.postgresql.fieldsJSONColumn())
 	} else {
 		cols = append(cols, tsrc.FieldColumns()...)
 	}
 
+	if tsrc.postgresql.RawColumn != "" && !tsrc.rawColumnDropped {
+		cols = append(cols, tsrc.postgresql.rawColumn())
+	}
+
 	return cols
 }
 
 func (tsrc *TableSource) TagTableColumns() []utils.Column {
 	cols := []utils.Column{
-		tagIDColumn,
+		tsrc.postgresql.tagIDColumn(),
 	}
 
 	cols = append(cols, tsrc.TagColumns()...)
@@ -203,9 +391,31 @@ func (tsrc *TableSource) DropColumn(col utils.Column) error {
 	}
 }
 
+// DropColumnMetrics drops the column from conversion, along with any metrics containing it.
+func (tsrc *TableSource) DropColumnMetrics(col utils.Column) error {
+	if col.Role != utils.FieldColType {
+		// Metrics containing a dropped tag are always skipped.
+		return tsrc.DropColumn(col)
+	}
+	if err := tsrc.dropFieldColumn(col); err != nil {
+		return err
+	}
+	if tsrc.droppedFieldMetrics == nil {
+		tsrc.droppedFieldMetrics = map[string]bool{}
+	}
+	tsrc.droppedFieldMetrics[col.Name] = true
+	return nil
+}
+
+// DropMetrics drops all metrics from conversion.
+func (tsrc *TableSource) DropMetrics() {
+	tsrc.metricsDropped = true
+	tsrc.tagSets = make(map[tagID][]*telegraf.Tag)
+}
+
 // Drops the tag column from conversion. Any metrics containing this tag will be skipped.
 func (tsrc *TableSource) dropTagColumn(col utils.Column) error {
-	if col.Role != utils.TagColType || tsrc.postgresql.TagsAsJsonb {
+	if col.Role != utils.TagColType || !tsrc.isTagColumn(col.Name) {
 		return fmt.Errorf("internal error: Tried to perform an invalid tag drop. measurement=%s tag=%s", tsrc.Name(), col.Name)
 	}
 	tsrc.droppedTagColumns = append(tsrc.droppedTagColumns, col.Name)
@@ -228,7 +438,11 @@ func (tsrc *TableSource) dropTagColumn(col utils.Column) error {
 
 // Drops the field column from conversion. Any metrics containing this field will have the field omitted.
 func (tsrc *TableSource) dropFieldColumn(col utils.Column) error {
-	if col.Role != utils.FieldColType || tsrc.postgresql.FieldsAsJsonb {
+	if tsrc.postgresql.RawColumn != "" && col.Name == tsrc.postgresql.RawColumn {
+		tsrc.rawColumnDropped = true
+		return nil
+	}
+	if col.Role != utils.FieldColType || tsrc.fieldsAsJsonb {
 		return fmt.Errorf("internal error: Tried to perform an invalid field drop. measurement=%s field=%s", tsrc.Name(), col.Name)
 	}
 
@@ -238,9 +452,13 @@ func (tsrc *TableSource) dropFieldColumn(col utils.Column) error {
 
 func (tsrc *TableSource) Next() bool {
 	for {
-		if tsrc.cursor+1 >= len(tsrc.metrics) {
+		if tsrc.metricsDropped || tsrc.cursor+1 >= len(tsrc.metrics) {
 			tsrc.cursorValues = nil
 			tsrc.cursorError = nil
+			if tsrc.row != nil {
+				putRow(tsrc.row)
+				tsrc.row = nil
+			}
 			return false
 		}
 		tsrc.cursor++
@@ -256,72 +474,162 @@ func (tsrc *TableSource) Reset() {
 	tsrc.cursor = -1
 }
 
+// ColumnDroppedMetrics returns the metrics which are skipped as they contain a tag, or with schema_mismatch_policy =
+// "drop_metrics" a field, whose column was dropped.
+func (tsrc *TableSource) ColumnDroppedMetrics() []telegraf.Metric {
+	if len(tsrc.droppedTagColumns) == 0 && len(tsrc.droppedFieldMetrics) == 0 {
+		return nil
+	}
+
+	var dropped []telegraf.Metric
+	for tsrc.cursor = 0; tsrc.cursor < len(tsrc.metrics); tsrc.cursor++ {
+		if _, err := tsrc.getValues(); err == nil && tsrc.cursorColumnDropped {
+			dropped = append(dropped, tsrc.metrics[tsrc.cursor])
+		}
+	}
+	if tsrc.row != nil {
+		putRow(tsrc.row)
+		tsrc.row = nil
+	}
+	tsrc.Reset()
+	return dropped
+}
+
 // getValues calculates the values for the metric at the cursor position.
 // If the metric cannot be emitted, such as due to dropped tags, or all fields dropped, the return value is nil.
+// The returned slice is reused for the next row.
 func (tsrc *TableSource) getValues() ([]interface{}, error) {
 	metric := tsrc.metrics[tsrc.cursor]
+	tsrc.cursorColumnDropped = false
 
-	values := []interface{}{
-		metric.Time().UTC(),
+	if tsrc.row == nil {
+		tsrc.row = rowPool.Get().(*[]interface{})
 	}
+	values := append((*tsrc.row)[:0], tsrc.postgresql.metricTimeValue(metric))
 
 	if !tsrc.postgresql.TagsAsForeignKeys {
-		if !tsrc.postgresql.TagsAsJsonb {
-			// tags_as_foreignkey=false, tags_as_json=false
-			tagValues := make([]interface{}, len(tsrc.tagColumns.columns))
-			for _, tag := range metric.TagList() {
-				tagPos, ok := tsrc.tagColumns.indices[tag.Key]
-				if !ok {
-					// tag has been dropped, we can't emit or we risk collision with another metric
-					return nil, nil
-				}
-				tagValues[tagPos] = tag.Value
+		// tags_as_foreignkey=false
+		var tagValues []interface{}
+		values, tagValues = appendNils(values, len(tsrc.tagColumns.columns))
+		var jsonTags []*telegraf.Tag
+		for _, tag := range metric.TagList() {
+			if !tsrc.isTagColumn(tag.Key) {
+				// tags_as_json=true, and the tag is not one of tag_columns
+				jsonTags = append(jsonTags, tag)
+				continue
 			}
-			values = append(values, tagValues...)
-		} else {
-			// tags_as_foreign_key=false, tags_as_json=true
-			values = append(values, utils.TagListToJSON(metric.TagList()))
+			tagPos, ok := tsrc.tagColumns.indices[tag.Key]
+			if !ok {
+				// tag has been dropped, we can't emit or we risk collision with another metric
+				tsrc.cursorColumnDropped = true
+				return nil, nil
+			}
+			tagValues[tagPos] = tag.Value
+		}
+		if tsrc.tagsAsJsonb {
+			values = append(values, utils.TagListToJSON(jsonTags))
 		}
 	} else {
 		// tags_as_foreignkey=true
-		tagID := utils.GetTagID(metric)
+		tagID := tsrc.postgresql.tagID(metric)
 		if tsrc.postgresql.ForeignTagConstraint {
 			if _, ok := tsrc.tagSets[tagID]; !ok {
 				// tag has been dropped
+				tsrc.cursorColumnDropped = true
 				return nil, nil
 			}
 		}
-		values = append(values, tagID)
+		values = append(values, tsrc.postgresql.tagIDValue(tagID))
 	}
 
-	if !tsrc.postgresql.FieldsAsJsonb {
+	if !tsrc.fieldsAsJsonb {
 		// fields_as_json=false
-		fieldValues := make([]interface{}, len(tsrc.fieldColumns.columns))
+		var fieldValues []interface{}
+		values, fieldValues = appendNils(values, len(tsrc.fieldColumns.columns))
 		fieldsEmpty := true
 		for _, field := range metric.FieldList() {
+			value, ok := tsrc.postgresql.fieldValue(field.Key, field.Value)
+			if !ok {
+				continue
+			}
+			name := field.Key
+			if tsrc.fieldColumnNames != nil {
+				typeValue := value
+				if typeValue == nil {
+					typeValue = field.Value
+				}
+				name = tsrc.fieldColumnNames[fieldColumnKey{key: field.Key, pgType: tsrc.postgresql.fieldPgDatatype(field.Key, typeValue)}]
+			}
 			// we might have dropped the field due to the table missing the column & schema updates being turned off
-			if fPos, ok := tsrc.fieldColumns.indices[field.Key]; ok {
-				fieldValues[fPos] = field.Value
+			if fPos, ok := tsrc.fieldColumns.indices[name]; ok {
+				if colType, ok := tsrc.fieldColumnTypes[name]; ok && value != nil {
+					value = convertFieldValue(value, colType)
+				}
+				fieldValues[fPos] = value
 				fieldsEmpty = false
+			} else if tsrc.droppedFieldMetrics[name] {
+				tsrc.cursorColumnDropped = true
+				return nil, nil
+			}
+		}
+		for _, gp := range tsrc.postgresql.geometryPoints {
+			value, ok := gp.value(metric)
+			if !ok {
+				continue
+			}
+			name := gp.column
+			if tsrc.fieldColumnNames != nil {
+				name = tsrc.fieldColumnNames[fieldColumnKey{key: gp.column, pgType: PgGeometry}]
+			}
+			if fPos, ok := tsrc.fieldColumns.indices[name]; ok {
+				fieldValues[fPos] = value
 			}
 		}
 		if fieldsEmpty {
 			// all fields have been dropped. Don't emit a metric with just tags and no fields.
 			return nil, nil
 		}
-		values = append(values, fieldValues...)
 	} else {
 		// fields_as_json=true
-		value, err := utils.FieldListToJSON(metric.FieldList())
+		fields := make([]*telegraf.Field, 0, len(metric.FieldList()))
+		for _, field := range metric.FieldList() {
+			value, ok := tsrc.postgresql.limitFieldValue(field.Value)
+			if !ok {
+				continue
+			}
+			fields = append(fields, &telegraf.Field{Key: field.Key, Value: value})
+		}
+		if len(fields) == 0 {
+			// all fields have been dropped. Don't emit a metric with just tags and no fields.
+			return nil, nil
+		}
+		var value []byte
+		var err error
+		if tsrc.postgresql.JSONType == PgJSON {
+			value, err = utils.FieldListToOrderedJSON(fields)
+		} else {
+			value, err = utils.FieldListToJSON(fields)
+		}
 		if err != nil {
 			return nil, err
 		}
 		values = append(values, value)
 	}
 
+	if tsrc.postgresql.RawColumn != "" && !tsrc.rawColumnDropped {
+		// Metrics which can't be serialized, such as due to NaN fields, are still written, without the raw metric.
+		if raw, err := utils.MetricToJSON(metric); err == nil {
+			values = append(values, raw)
+		} else {
+			values = append(values, nil)
+		}
+	}
+
+	*tsrc.row = values
 	return values, nil
 }
 
+// Values returns the values of the current row. The slice is only valid until the next call to Next.
 func (tsrc *TableSource) Values() ([]interface{}, error) {
 	return tsrc.cursorValues, tsrc.cursorError
 }
@@ -332,11 +640,12 @@ func (tsrc *TableSource) Err() error {
 
 type TagTableSource struct {
 	*TableSource
-	tagIDs []int64
+	tagIDs []tagID
 
 	cursor       int
 	cursorValues []interface{}
 	cursorError  error
+	row          *[]interface{}
 }
 
 func NewTagTableSource(tsrc *TableSource) *TagTableSource {
@@ -345,7 +654,7 @@ func NewTagTableSource(tsrc *TableSource) *TagTableSource {
 		cursor:      -1,
 	}
 
-	ttsrc.tagIDs = make([]int64, 0, len(tsrc.tagSets))
+	ttsrc.tagIDs = make([]tagID, 0, len(tsrc.tagSets))
 	for tagID := range tsrc.tagSets {
 		ttsrc.tagIDs = append(ttsrc.tagIDs, tagID)
 	}
@@ -357,16 +666,21 @@ func (ttsrc *TagTableSource) Name() string {
 	return ttsrc.TableSource.Name() + ttsrc.postgresql.TagTableSuffix
 }
 
-func (ttsrc *TagTableSource) cacheCheck(tagID int64) bool {
+// tagHashSalt returns the salt of the tag IDs of the named measurement in the tag cache.
+func tagHashSalt(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+func (ttsrc *TagTableSource) cacheCheck(tagID tagID) bool {
 	// Adding the 2 hashes is good enough. It's not a perfect solution, but given that we're operating in an int64
 	// space, the risk of collision is extremely small.
-	key := ttsrc.tagHashSalt + tagID
-	_, err := ttsrc.postgresql.tagsCache.GetInt(key)
+	_, err := ttsrc.postgresql.tagsCache.Get(ttsrc.postgresql.tagCacheKey(ttsrc.tagHashSalt, tagID))
 	return err == nil
 }
-func (ttsrc *TagTableSource) cacheTouch(tagID int64) {
-	key := ttsrc.tagHashSalt + tagID
-	_ = ttsrc.postgresql.tagsCache.SetInt(key, nil, 0)
+func (ttsrc *TagTableSource) cacheTouch(tagID tagID) {
+	_ = ttsrc.postgresql.tagsCache.Set(ttsrc.postgresql.tagCacheKey(ttsrc.tagHashSalt, tagID), nil, 0)
 }
 
 func (ttsrc *TagTableSource) ColumnNames() []string {
@@ -382,6 +696,10 @@ func (ttsrc *TagTableSource) Next() bool {
 	for {
 		if ttsrc.cursor+1 >= len(ttsrc.tagIDs) {
 			ttsrc.cursorValues = nil
+			if ttsrc.row != nil {
+				putRow(ttsrc.row)
+				ttsrc.row = nil
+			}
 			return false
 		}
 		ttsrc.cursor++
@@ -402,25 +720,42 @@ func (ttsrc *TagTableSource) Reset() {
 	ttsrc.cursor = -1
 }
 
+// countRows counts the rows to be written, stopping at limit, and resets the cursor.
+func (ttsrc *TagTableSource) countRows(limit int) int {
+	defer ttsrc.Reset()
+	n := 0
+	for n < limit && ttsrc.Next() {
+		n++
+	}
+	return n
+}
+
 func (ttsrc *TagTableSource) getValues() []interface{} {
 	tagID := ttsrc.tagIDs[ttsrc.cursor]
 	tagSet := ttsrc.tagSets[tagID]
 
-	var values []interface{}
-	if !ttsrc.postgresql.TagsAsJsonb {
-		values = make([]interface{}, len(ttsrc.TableSource.tagColumns.indices)+1)
-		for _, tag := range tagSet {
-			values[ttsrc.TableSource.tagColumns.indices[tag.Key]+1] = tag.Value // +1 to account for tag_id column
+	if ttsrc.row == nil {
+		ttsrc.row = rowPool.Get().(*[]interface{})
+	}
+	values, _ := appendNils((*ttsrc.row)[:0], len(ttsrc.TableSource.tagColumns.indices)+1)
+	var jsonTags []*telegraf.Tag
+	for _, tag := range tagSet {
+		if !ttsrc.isTagColumn(tag.Key) {
+			jsonTags = append(jsonTags, tag)
+			continue
 		}
-	} else {
-		values = make([]interface{}, 2)
-		values[1] = utils.TagListToJSON(tagSet)
+		values[ttsrc.TableSource.tagColumns.indices[tag.Key]+1] = tag.Value // +1 to account for tag_id column
+	}
+	if ttsrc.tagsAsJsonb {
+		values = append(values, utils.TagListToJSON(jsonTags))
 	}
-	values[0] = tagID
+	values[0] = ttsrc.postgresql.tagIDValue(tagID)
 
+	*ttsrc.row = values
 	return values
 }
 
+// Values returns the values of the current row. The slice is only valid until the next call to Next.
 func (ttsrc *TagTableSource) Values() ([]interface{}, error) {
 	return ttsrc.cursorValues, ttsrc.cursorError
 }
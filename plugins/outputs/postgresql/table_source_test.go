@@ -1,7 +1,9 @@
 package postgresql
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"math"
 	"testing"
 	"time"
 
@@ -56,6 +58,48 @@ func TestTableSource_tagJSONB(t *testing.T) {
 	assert.EqualValues(t, 1, row["v"])
 }
 
+func TestTableSource_tagJSONB_tagColumns(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsJsonb = true
+	p.TagColumns = []string{"a"}
+	require.NoError(t, p.Init())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"a": "one", "b": "two", "c": "three"}, MSI{"v": 1}),
+	}
+
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	assert.Equal(t, []string{"time", "a", "tags", "v"}, tsrc.ColumnNames())
+	row := nextSrcRow(tsrc)
+	require.NoError(t, tsrc.Err())
+
+	assert.EqualValues(t, "one", row["a"])
+	var tags MSI
+	require.NoError(t, json.Unmarshal(row["tags"].([]byte), &tags))
+	assert.EqualValues(t, MSI{"b": "two", "c": "three"}, tags)
+}
+
+func TestTableSource_tagTableJSONB_tagColumns(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsForeignKeys = true
+	p.TagsAsJsonb = true
+	p.TagColumns = []string{"a"}
+	require.NoError(t, p.Init())
+	p.tagsCache = freecache.NewCache(5 * 1024 * 1024)
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"a": "one", "b": "two"}, MSI{"v": 1}),
+	}
+
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	ttsrc := NewTagTableSource(tsrc)
+	ttrow := nextSrcRow(ttsrc)
+	assert.EqualValues(t, "one", ttrow["a"])
+	var tags MSI
+	require.NoError(t, json.Unmarshal(ttrow["tags"].([]byte), &tags))
+	assert.EqualValues(t, MSI{"b": "two"}, tags)
+}
+
 func TestTableSource_tagTable(t *testing.T) {
 	p := newPostgresqlTest(t)
 	p.TagsAsForeignKeys = true
@@ -109,6 +153,143 @@ func TestTableSource_fieldsJSONB(t *testing.T) {
 	assert.EqualValues(t, MSI{"a": 1.0, "b": 2.0}, fields)
 }
 
+func TestTableSource_fieldsJSON(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.FieldsAsJsonb = true
+	p.JSONType = PgJSON
+
+	m := newMetric(t, "", MSS{"tag": "foo"}, MSI{"b": 2})
+	m.AddField("a", 1)
+	metrics := []telegraf.Metric{m}
+
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	assert.Equal(t, PgJSON, tsrc.MetricTableColumns()[2].Type)
+	row := nextSrcRow(tsrc)
+	// json preserves the order of the fields
+	assert.Equal(t, `{"b":2,"a":1}`, string(row["fields"].([]byte)))
+}
+
+func TestTableSource_fieldsJSONB_limits(t *testing.T) {
+	p := newPostgresql()
+	p.FieldsAsJsonb = true
+	p.NonFiniteFloats = nonFiniteNull
+	p.MaxStringLength = 3
+	require.NoError(t, p.Init())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": math.NaN(), "b": math.Inf(1), "c": "abcd", "d": 1}),
+	}
+
+	tsrc := NewTableSources(p, metrics)[t.Name()]
+	row := nextSrcRow(tsrc)
+	var fields MSI
+	require.NoError(t, json.Unmarshal(row["fields"].([]byte), &fields))
+	assert.EqualValues(t, MSI{"a": nil, "b": nil, "c": "abc", "d": 1.0}, fields)
+
+	p.NonFiniteFloats = nonFiniteDrop
+	p.StringLengthPolicy = stringLengthDrop
+	tsrc = NewTableSources(p, metrics)[t.Name()]
+	row = nextSrcRow(tsrc)
+	fields = nil
+	require.NoError(t, json.Unmarshal(row["fields"].([]byte), &fields))
+	assert.EqualValues(t, MSI{"d": 1.0}, fields)
+}
+
+func TestTableSource_geometryPoints(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.GeometryPoints = []string{"location:lat:lon"}
+	require.NoError(t, p.Init())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"lat": 51.5, "lon": -0.1}),
+		newMetric(t, "", nil, MSI{"lat": 51.5}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	assert.ElementsMatch(t, []string{"time", "lat", "location", "lon"}, tsrc.ColumnNames())
+	for _, col := range p.translateColumns(tsrc.FieldColumns()) {
+		if col.Name == "location" {
+			assert.Equal(t, PgGeometryPoint, col.Type)
+		}
+	}
+
+	row := nextSrcRow(tsrc)
+	point := row["location"].(geometryPointValue)
+	ewkb, err := point.EncodeBinary(nil, nil)
+	require.NoError(t, err)
+	require.Len(t, ewkb, 25)
+	assert.Equal(t, uint32(4326), binary.LittleEndian.Uint32(ewkb[5:]))
+	assert.Equal(t, -0.1, math.Float64frombits(binary.LittleEndian.Uint64(ewkb[9:])))
+	assert.Equal(t, 51.5, math.Float64frombits(binary.LittleEndian.Uint64(ewkb[17:])))
+	ewkt, err := point.EncodeText(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SRID=4326;POINT(-0.1 51.5)", string(ewkt))
+
+	row = nextSrcRow(tsrc)
+	assert.Nil(t, row["location"])
+
+	p = newPostgresqlTest(t)
+	p.GeometryPoints = []string{"location:lat"}
+	require.Error(t, p.Init())
+}
+
+func TestTableSource_nonFiniteNull(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.NonFiniteFloats = nonFiniteNull
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", nil, MSI{"a": math.NaN(), "b": 1}),
+	}
+	tsrc := NewTableSources(p.Postgresql, metrics)[t.Name()]
+	assert.Equal(t, PgDoublePrecision, tsrc.fieldColumns.columns[tsrc.fieldColumns.indices["a"]].Type)
+	row := nextSrcRow(tsrc)
+	assert.Nil(t, row["a"])
+	assert.EqualValues(t, 1, row["b"])
+}
+
+func TestTableSource_rawColumn(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.RawColumn = "raw"
+
+	m := newMetric(t, "", MSS{"tag": "foo"}, MSI{"a": 1})
+	tsrc := NewTableSources(p.Postgresql, []telegraf.Metric{m})[t.Name()]
+	assert.Equal(t, []string{"time", "tag", "a", "raw"}, tsrc.ColumnNames())
+
+	row := nextSrcRow(tsrc)
+	var raw MSI
+	require.NoError(t, json.Unmarshal(row["raw"].([]byte), &raw))
+	assert.Equal(t, MSI{
+		"name":      t.Name(),
+		"tags":      MSI{"tag": "foo"},
+		"fields":    MSI{"a": 1.0},
+		"timestamp": float64(m.Time().UnixNano()),
+	}, raw)
+
+	// Without the column, the raw metric is omitted.
+	tsrc.Reset()
+	require.NoError(t, tsrc.DropColumn(p.rawColumn()))
+	assert.Equal(t, []string{"time", "tag", "a"}, tsrc.ColumnNames())
+	row = nextSrcRow(tsrc)
+	assert.NotContains(t, row, "raw")
+}
+
+func TestTableSource_jsonbMeasurements(t *testing.T) {
+	p := newPostgresqlTest(t)
+	p.TagsAsJsonbMeasurements = []string{t.Name() + "_t*"}
+	p.FieldsAsJsonbMeasurements = []string{t.Name() + "_f*"}
+	require.NoError(t, p.Init())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "_tags", MSS{"tag": "foo"}, MSI{"a": 1}),
+		newMetric(t, "_fields", MSS{"tag": "foo"}, MSI{"a": 1}),
+		newMetric(t, "_none", MSS{"tag": "foo"}, MSI{"a": 1}),
+	}
+	tsrcs := NewTableSources(p.Postgresql, metrics)
+
+	assert.Equal(t, []string{"time", "tags", "a"}, tsrcs[t.Name()+"_tags"].ColumnNames())
+	assert.Equal(t, []string{"time", "tag", "fields"}, tsrcs[t.Name()+"_fields"].ColumnNames())
+	assert.Equal(t, []string{"time", "tag", "a"}, tsrcs[t.Name()+"_none"].ColumnNames())
+}
+
 // TagsAsForeignKeys=false
 // Test that when a tag column is dropped, all metrics containing that tag are dropped.
 func TestTableSource_DropColumn_tag(t *testing.T) {
@@ -231,6 +412,36 @@ func TestTableSource_DropColumn_field(t *testing.T) {
 	assert.False(t, tsrc.Next())
 }
 
+// Test that the metrics skipped due to a dropped column are reported, but not those merely missing a field.
+func TestTableSource_ColumnDroppedMetrics(t *testing.T) {
+	p := newPostgresql()
+	require.NoError(t, p.Init())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{"a": "one", "b": "two"}, MSI{"v": 1}),
+		newMetric(t, "", MSS{"a": "one"}, MSI{"v": 2}),
+		newMetric(t, "", MSS{"a": "one"}, MSI{"v": 3, "w": 4}),
+	}
+	tsrc := NewTableSources(p, metrics)[t.Name()]
+	assert.Empty(t, tsrc.ColumnDroppedMetrics())
+
+	for _, c := range tsrc.TagColumns() {
+		if c.Name == "b" {
+			require.NoError(t, tsrc.DropColumnMetrics(c))
+		}
+	}
+	for _, c := range tsrc.FieldColumns() {
+		if c.Name == "w" {
+			require.NoError(t, tsrc.DropColumnMetrics(c))
+		}
+	}
+	assert.Equal(t, []telegraf.Metric{metrics[0], metrics[2]}, tsrc.ColumnDroppedMetrics())
+
+	row := nextSrcRow(tsrc)
+	assert.EqualValues(t, 2, row["v"])
+	assert.False(t, tsrc.Next())
+}
+
 func TestTableSource_InconsistentTags(t *testing.T) {
 	p := newPostgresqlTest(t)
 
@@ -0,0 +1,93 @@
+package postgresql
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
+)
+
+// TableTemplates are templates used for the tables of the measurements matching Measurements, in place of those of
+// the plugin. Templates which are not set are taken from the plugin.
+type TableTemplates struct {
+	Measurements                []string                `toml:"measurements"`
+	CreateTemplates             []*sqltemplate.Template `toml:"create_templates"`
+	CreateIndexTemplates        []*sqltemplate.Template `toml:"create_index_templates"`
+	PostCreateTemplates         []*sqltemplate.Template `toml:"post_create_templates"`
+	PostWriteTemplates          []*sqltemplate.Template `toml:"post_write_templates"`
+	AddColumnTemplates          []*sqltemplate.Template `toml:"add_column_templates"`
+	TagTableCreateTemplates     []*sqltemplate.Template `toml:"tag_table_create_templates"`
+	TagTableAddColumnTemplates  []*sqltemplate.Template `toml:"tag_table_add_column_templates"`
+	TagTablePostCreateTemplates []*sqltemplate.Template `toml:"tag_table_post_create_templates"`
+
+	filter filter.Filter
+}
+
+// initTableTemplates compiles the measurement patterns of table_templates.
+func (p *Postgresql) initTableTemplates() error {
+	for i, tt := range p.TableTemplates {
+		if len(tt.Measurements) == 0 {
+			return fmt.Errorf("table_templates entry %d: measurements must not be empty", i+1)
+		}
+		var err error
+		if tt.filter, err = filter.Compile(tt.Measurements); err != nil {
+			return fmt.Errorf("table_templates entry %d: invalid measurements: %w", i+1, err)
+		}
+
+		if p.NoDDL || p.MigrationsDir != "" {
+			// No DDL is executed, so only the post-write templates apply.
+			*tt = TableTemplates{
+				Measurements:       tt.Measurements,
+				PostWriteTemplates: tt.PostWriteTemplates,
+				filter:             tt.filter,
+			}
+		}
+	}
+	return nil
+}
+
+// tableTemplates returns the templates for the tables of the given measurement: those of the first table_templates
+// entry matching it, with any it doesn't set taken from the plugin.
+func (p *Postgresql) tableTemplates(measurement string) *TableTemplates {
+	tmpls := &TableTemplates{
+		CreateTemplates:             p.CreateTemplates,
+		CreateIndexTemplates:        p.CreateIndexTemplates,
+		PostCreateTemplates:         p.PostCreateTemplates,
+		PostWriteTemplates:          p.PostWriteTemplates,
+		AddColumnTemplates:          p.AddColumnTemplates,
+		TagTableCreateTemplates:     p.TagTableCreateTemplates,
+		TagTableAddColumnTemplates:  p.TagTableAddColumnTemplates,
+		TagTablePostCreateTemplates: p.TagTablePostCreateTemplates,
+	}
+	for _, tt := range p.TableTemplates {
+		if !tt.filter.Match(measurement) {
+			continue
+		}
+		if tt.CreateTemplates != nil {
+			tmpls.CreateTemplates = tt.CreateTemplates
+		}
+		if tt.CreateIndexTemplates != nil {
+			tmpls.CreateIndexTemplates = tt.CreateIndexTemplates
+		}
+		if tt.PostCreateTemplates != nil {
+			tmpls.PostCreateTemplates = tt.PostCreateTemplates
+		}
+		if tt.PostWriteTemplates != nil {
+			tmpls.PostWriteTemplates = tt.PostWriteTemplates
+		}
+		if tt.AddColumnTemplates != nil {
+			tmpls.AddColumnTemplates = tt.AddColumnTemplates
+		}
+		if tt.TagTableCreateTemplates != nil {
+			tmpls.TagTableCreateTemplates = tt.TagTableCreateTemplates
+		}
+		if tt.TagTableAddColumnTemplates != nil {
+			tmpls.TagTableAddColumnTemplates = tt.TagTableAddColumnTemplates
+		}
+		if tt.TagTablePostCreateTemplates != nil {
+			tmpls.TagTablePostCreateTemplates = tt.TagTablePostCreateTemplates
+		}
+		break
+	}
+	return tmpls
+}
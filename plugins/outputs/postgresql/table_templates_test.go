@@ -0,0 +1,60 @@
+package postgresql
+
+import (
+	"testing"
+
+	"github.com/influxdata/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresql_tableTemplates(t *testing.T) {
+	p := newPostgresql()
+	require.NoError(t, toml.Unmarshal([]byte(`
+[[table_templates]]
+  measurements = ["cpu*"]
+  create_templates = ['''CREATE TABLE {{ .table }} ({{ .columns }}) -- cpu''']
+
+[[table_templates]]
+  measurements = ["cpu_total", "disk"]
+  add_column_templates = []
+`), p))
+	require.NoError(t, p.Init())
+
+	cpu := p.tableTemplates("cpu_total")
+	require.Len(t, cpu.CreateTemplates, 1)
+	assert.NotSame(t, p.CreateTemplates[0], cpu.CreateTemplates[0])
+	// Only the first matching entry is used.
+	assert.Equal(t, p.AddColumnTemplates, cpu.AddColumnTemplates)
+	assert.Equal(t, p.TagTableCreateTemplates, cpu.TagTableCreateTemplates)
+
+	disk := p.tableTemplates("disk")
+	assert.Equal(t, p.CreateTemplates, disk.CreateTemplates)
+	assert.Empty(t, disk.AddColumnTemplates)
+
+	mem := p.tableTemplates("mem")
+	assert.Equal(t, p.CreateTemplates, mem.CreateTemplates)
+	assert.Equal(t, p.AddColumnTemplates, mem.AddColumnTemplates)
+}
+
+func TestPostgresql_tableTemplates_invalid(t *testing.T) {
+	p := newPostgresql()
+	p.TableTemplates = []*TableTemplates{{}}
+	err := p.Init()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "measurements must not be empty")
+
+	p = newPostgresql()
+	p.TableTemplates = []*TableTemplates{{Measurements: []string{"cpu[*"}}}
+	err = p.Init()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid measurements")
+}
+
+func TestPostgresql_tableTemplates_noDDL(t *testing.T) {
+	p := newPostgresql()
+	p.NoDDL = true
+	p.TableTemplates = []*TableTemplates{{Measurements: []string{"cpu"}}}
+	require.NoError(t, p.Init())
+	assert.Empty(t, p.tableTemplates("cpu").CreateTemplates)
+}
@@ -0,0 +1,136 @@
+package postgresql
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// preloadTagCache adds the tag IDs most recently inserted into each tag table, up to tag_cache_preload per table, to
+// the tag cache. Tag tables have no column recording when a tag was inserted, so the physical order of their rows is
+// taken as the insertion order. This is only approximate: once rows are deleted, such as by tag_prune_interval, and
+// their space is reused, newer tags may be stored before older ones. Either way, a tag which isn't preloaded is only
+// inserted again.
+func (p *Postgresql) preloadTagCache() error {
+	tagTables, err := p.tagTables()
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, tagTable := range tagTables {
+		salt := tagHashSalt(tagTable[:len(tagTable)-len(p.TagTableSuffix)])
+		ident := utils.FullTableName(p.Schema, tagTable)
+		rows, err := p.db.Query(p.dbContext,
+			"SELECT tag_id::text FROM "+ident.Sanitize()+" ORDER BY ctid DESC LIMIT $1", p.TagCachePreload)
+		if err != nil {
+			return fmt.Errorf("reading tag table '%s': %w", tagTable, err)
+		}
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				rows.Close()
+				return fmt.Errorf("reading tag table '%s': %w", tagTable, err)
+			}
+			tagID, err := p.parseTagID(value)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("reading tag table '%s': %w", tagTable, err)
+			}
+			_ = p.tagsCache.Set(p.tagCacheKey(salt, tagID), nil, 0)
+			count++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("reading tag table '%s': %w", tagTable, err)
+		}
+	}
+	p.Logger.Debugf("preloaded %d tag IDs from %d tag tables into the tag cache", count, len(tagTables))
+	return nil
+}
+
+// tagTables returns the names of the tag tables in the schema, identified by their suffix and tag_id column.
+func (p *Postgresql) tagTables() ([]string, error) {
+	rows, err := p.db.Query(p.dbContext, `SELECT table_name FROM information_schema.columns
+		WHERE table_schema = coalesce(nullif($1, ''), current_schema()) AND right(table_name, length($2)) = $2
+			AND column_name = 'tag_id'`,
+		p.Schema, p.TagTableSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var tagTables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tagTables = append(tagTables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tagTables, nil
+}
+
+// loadTagCache restores the tag cache saved by saveTagCache. A missing file is not an error, as there is nothing to
+// restore on the first start.
+func (p *Postgresql) loadTagCache() error {
+	data, err := os.ReadFile(p.TagCacheFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	keyLen := p.tagCacheKeyLen()
+	if len(data)%keyLen != 0 {
+		return fmt.Errorf("file is truncated")
+	}
+	for i := 0; i < len(data); i += keyLen {
+		_ = p.tagsCache.Set(data[i:i+keyLen], nil, 0)
+	}
+	p.Logger.Debugf("restored %d tag IDs into the tag cache", len(data)/keyLen)
+	return nil
+}
+
+// saveTagCache writes the keys of the tag cache to tag_cache_file. The file is written alongside and then renamed over
+// the old one, so that a crash while saving doesn't leave a partial file.
+func (p *Postgresql) saveTagCache() error {
+	p.tagCacheSaveMutex.Lock()
+	defer p.tagCacheSaveMutex.Unlock()
+
+	keyLen := p.tagCacheKeyLen()
+	data := make([]byte, 0, p.tagsCache.EntryCount()*int64(keyLen))
+	it := p.tagsCache.NewIterator()
+	for entry := it.Next(); entry != nil; entry = it.Next() {
+		if len(entry.Key) == keyLen {
+			data = append(data, entry.Key...)
+		}
+	}
+
+	tmpFile := p.TagCacheFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, p.TagCacheFile)
+}
+
+// tagCacheSaveWorker saves the tag cache every tag_cache_save_interval, until the plugin is closed.
+func (p *Postgresql) tagCacheSaveWorker() {
+	ticker := time.NewTicker(time.Duration(p.TagCacheSaveInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.saveTagCache(); err != nil {
+				p.Logger.Errorf("Couldn't save tag cache to %s\n%v", p.TagCacheFile, err)
+			}
+		case <-p.dbContext.Done():
+			return
+		}
+	}
+}
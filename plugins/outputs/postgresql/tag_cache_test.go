@@ -0,0 +1,58 @@
+package postgresql
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/coocood/freecache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagCache_saveLoad(t *testing.T) {
+	p := newPostgresql()
+	p.Logger = NewLogAccumulator(t)
+	p.TagCacheFile = filepath.Join(t.TempDir(), "tag_cache")
+	require.NoError(t, p.Init())
+
+	// A missing file leaves the cache empty.
+	p.tagsCache = freecache.NewCache(1024 * 1024)
+	require.NoError(t, p.loadTagCache())
+	assert.EqualValues(t, 0, p.tagsCache.EntryCount())
+
+	for _, key := range []int64{1, -2, 1 << 62} {
+		require.NoError(t, p.tagsCache.SetInt(key, nil, 0))
+	}
+	require.NoError(t, p.saveTagCache())
+
+	p.tagsCache = freecache.NewCache(1024 * 1024)
+	require.NoError(t, p.loadTagCache())
+	assert.EqualValues(t, 3, p.tagsCache.EntryCount())
+	for _, key := range []int64{1, -2, 1 << 62} {
+		_, err := p.tagsCache.GetInt(key)
+		assert.NoError(t, err)
+	}
+}
+
+func TestTagCache_saveLoad_tagIDHash128(t *testing.T) {
+	p := newPostgresql()
+	p.Logger = NewLogAccumulator(t)
+	p.TagIDHash = "fnv128a"
+	p.TagCacheFile = filepath.Join(t.TempDir(), "tag_cache")
+	require.NoError(t, p.Init())
+
+	ids := []tagID{{hi: 1, lo: 2}, {hi: 1, lo: 3}, {hi: 2, lo: 2}}
+	p.tagsCache = freecache.NewCache(1024 * 1024)
+	for _, id := range ids {
+		require.NoError(t, p.tagsCache.Set(p.tagCacheKey(7, id), nil, 0))
+	}
+	require.NoError(t, p.saveTagCache())
+
+	p.tagsCache = freecache.NewCache(1024 * 1024)
+	require.NoError(t, p.loadTagCache())
+	assert.EqualValues(t, 3, p.tagsCache.EntryCount())
+	for _, id := range ids {
+		_, err := p.tagsCache.Get(p.tagCacheKey(7, id))
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,136 @@
+package postgresql
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// tagID is the ID of a tag set, as derived by tag_id_hash. The IDs of 64-bit hashes are held in lo alone, while those
+// of 128-bit hashes are split between hi and lo.
+type tagID struct {
+	hi uint64
+	lo int64
+}
+
+func (id tagID) less(other tagID) bool {
+	if id.hi != other.hi {
+		return id.hi < other.hi
+	}
+	return id.lo < other.lo
+}
+
+// bytes returns the 128-bit ID in big-endian byte order.
+func (id tagID) bytes() [16]byte {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], id.hi)
+	binary.BigEndian.PutUint64(b[8:], uint64(id.lo))
+	return b
+}
+
+func tagIDFromBytes(b [16]byte) tagID {
+	return tagID{hi: binary.BigEndian.Uint64(b[:8]), lo: int64(binary.BigEndian.Uint64(b[8:]))}
+}
+
+// tagIDHashes are the functions deriving the tag ID of a metric, by tag_id_hash, and whether the IDs are 128-bit.
+var tagIDHashes = map[string]struct {
+	fn   func(telegraf.Metric) tagID
+	wide bool
+}{
+	"fnv64a":   {fn: func(m telegraf.Metric) tagID { return tagID{lo: utils.GetTagID(m)} }},
+	"xxhash64": {fn: func(m telegraf.Metric) tagID { return tagID{lo: utils.GetTagIDXXHash(m)} }},
+	"fnv128a":  {fn: func(m telegraf.Metric) tagID { return tagIDFromBytes(utils.GetTagIDFNV128a(m)) }, wide: true},
+}
+
+// initTagID sets up the derivation of tag IDs as per tag_id_hash, and the data type of the tag_id column as per
+// tag_id_type, which defaults to bigint for 64-bit IDs and uuid for 128-bit IDs.
+func (p *Postgresql) initTagID() error {
+	if p.TagIDHash == "" {
+		p.TagIDHash = "fnv64a"
+	}
+	hash, ok := tagIDHashes[p.TagIDHash]
+	if !ok {
+		return fmt.Errorf("invalid tag_id_hash %q", p.TagIDHash)
+	}
+	p.tagID = hash.fn
+
+	switch p.TagIDType {
+	case "":
+		p.TagIDType = PgBigInt
+		if hash.wide {
+			p.TagIDType = PgUUID
+		}
+	case PgBigInt:
+		if hash.wide {
+			return fmt.Errorf("tag_id_type %q cannot hold the 128-bit IDs of tag_id_hash %q", p.TagIDType, p.TagIDHash)
+		}
+	case PgUUID, PgNumeric:
+		if !hash.wide {
+			return fmt.Errorf("tag_id_type %q requires a 128-bit tag_id_hash", p.TagIDType)
+		}
+	default:
+		return fmt.Errorf("invalid tag_id_type %q", p.TagIDType)
+	}
+	return nil
+}
+
+// tagIDValue returns the value of the tag ID written to the tag_id column.
+func (p *Postgresql) tagIDValue(id tagID) interface{} {
+	switch p.TagIDType {
+	case PgUUID:
+		return id.bytes()
+	case PgNumeric:
+		b := id.bytes()
+		return new(big.Int).SetBytes(b[:]).String()
+	default:
+		return id.lo
+	}
+}
+
+// parseTagID parses the text representation of a value of the tag_id column.
+func (p *Postgresql) parseTagID(s string) (tagID, error) {
+	switch p.TagIDType {
+	case PgUUID:
+		var b [16]byte
+		if n, err := hex.Decode(b[:], []byte(strings.ReplaceAll(s, "-", ""))); err != nil || n != len(b) {
+			return tagID{}, fmt.Errorf("invalid uuid tag ID %q", s)
+		}
+		return tagIDFromBytes(b), nil
+	case PgNumeric:
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok || i.Sign() < 0 || i.BitLen() > 128 {
+			return tagID{}, fmt.Errorf("invalid numeric tag ID %q", s)
+		}
+		var b [16]byte
+		i.FillBytes(b[:])
+		return tagIDFromBytes(b), nil
+	default:
+		lo, err := strconv.ParseInt(s, 10, 64)
+		return tagID{lo: lo}, err
+	}
+}
+
+// tagCacheKeyLen returns the length of the keys of the tag cache: 8 bytes for 64-bit tag IDs, or 16 for 128-bit IDs.
+func (p *Postgresql) tagCacheKeyLen() int {
+	if p.TagIDType == PgBigInt {
+		return 8
+	}
+	return 16
+}
+
+// tagCacheKey returns the key of the tag ID in the tag cache, combined with the salt of its table. The keys of 64-bit
+// tag IDs are those of freecache's SetInt, of the sum of the salt and ID.
+func (p *Postgresql) tagCacheKey(salt int64, id tagID) []byte {
+	key := make([]byte, p.tagCacheKeyLen())
+	binary.LittleEndian.PutUint64(key, uint64(salt+id.lo))
+	if len(key) > 8 {
+		binary.LittleEndian.PutUint64(key[8:], id.hi)
+	}
+	return key
+}
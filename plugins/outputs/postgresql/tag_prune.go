@@ -0,0 +1,86 @@
+package postgresql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// pruneTags deletes the rows of each tag table whose tag_id doesn't appear in the metric table within the last
+// tag_prune_retention, or at all when it is 0. The deleted tag IDs are removed from the tag cache, so that they are
+// inserted again should the series reappear.
+func (p *Postgresql) pruneTags() error {
+	tagTables, err := p.tagTables()
+	if err != nil {
+		return err
+	}
+
+	var cutoff interface{}
+	if p.TagPruneRetention > 0 {
+		cutoff = p.timeValue(time.Now().UTC().Add(-time.Duration(p.TagPruneRetention)))
+	}
+
+	count := 0
+	for _, tagTable := range tagTables {
+		n, err := p.pruneTagTable(tagTable, cutoff)
+		if err != nil {
+			// Carry on with the other tables, as this one may lack its metric table.
+			p.Logger.Errorf("Couldn't prune tag table '%s'\n%v", tagTable, err)
+			continue
+		}
+		count += n
+	}
+	p.Logger.Debugf("pruned %d tags from %d tag tables", count, len(tagTables))
+	return nil
+}
+
+// pruneTagTable deletes the unused rows of the tag table, as per pruneTags, returning the number of rows deleted.
+func (p *Postgresql) pruneTagTable(tagTable string, cutoff interface{}) (int, error) {
+	metricTable := tagTable[:len(tagTable)-len(p.TagTableSuffix)]
+	sql := fmt.Sprintf("DELETE FROM %s t WHERE NOT EXISTS (SELECT 1 FROM %s m WHERE m.tag_id = t.tag_id",
+		utils.FullTableName(p.Schema, tagTable).Sanitize(), utils.FullTableName(p.Schema, metricTable).Sanitize())
+	var args []interface{}
+	if cutoff != nil {
+		sql += " AND m.time >= $1"
+		args = append(args, cutoff)
+	}
+	sql += ") RETURNING t.tag_id::text"
+
+	rows, err := p.db.Query(p.dbContext, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	salt := tagHashSalt(metricTable)
+	count := 0
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return count, err
+		}
+		tagID, err := p.parseTagID(value)
+		if err != nil {
+			return count, err
+		}
+		p.tagsCache.Del(p.tagCacheKey(salt, tagID))
+		count++
+	}
+	return count, rows.Err()
+}
+
+// tagPruneWorker prunes the tag tables every tag_prune_interval, until the plugin is closed.
+func (p *Postgresql) tagPruneWorker() {
+	ticker := time.NewTicker(time.Duration(p.TagPruneInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.pruneTags(); err != nil {
+				p.Logger.Errorf("Couldn't prune tag tables\n%v", err)
+			}
+		case <-p.dbContext.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package postgresql
+
+import (
+	"github.com/jackc/pgconn"
+)
+
+// configureTLS sets the TLS configuration of the connections from the tls_* options, if any are set. As with sslmode
+// require, no fallback to an unencrypted connection is made. Each host of connection is verified against its own name,
+// unless tls_server_name is set.
+func (p *Postgresql) configureTLS() error {
+	tlsConfig, err := p.ClientConfig.TLSConfig()
+	if err != nil || tlsConfig == nil {
+		return err
+	}
+
+	// The fallbacks of sslmode allow and prefer try each host both with and without TLS, so only the first for each
+	// host is kept.
+	connConfig := p.dbConfig.ConnConfig
+	hosts := []*pgconn.FallbackConfig{{Host: connConfig.Host, Port: connConfig.Port}}
+	for _, fallback := range connConfig.Fallbacks {
+		last := hosts[len(hosts)-1]
+		if fallback.Host != last.Host || fallback.Port != last.Port {
+			hosts = append(hosts, &pgconn.FallbackConfig{Host: fallback.Host, Port: fallback.Port})
+		}
+	}
+	for _, host := range hosts {
+		if network, _ := pgconn.NetworkAddress(host.Host, host.Port); network == "unix" {
+			// Connections over unix sockets don't use TLS.
+			continue
+		}
+		host.TLSConfig = tlsConfig.Clone()
+		if host.TLSConfig.ServerName == "" {
+			host.TLSConfig.ServerName = host.Host
+		}
+	}
+
+	connConfig.TLSConfig = hosts[0].TLSConfig
+	connConfig.Fallbacks = hosts[1:]
+	return nil
+}
@@ -0,0 +1,34 @@
+package postgresql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresql_configureTLS(t *testing.T) {
+	p := newPostgresql()
+	p.Connection = "host=db1,db2 sslmode=prefer"
+	require.NoError(t, p.Init())
+	require.Len(t, p.dbConfig.ConnConfig.Fallbacks, 3)
+
+	p = newPostgresql()
+	p.Connection = "host=db1,db2,/tmp sslmode=prefer"
+	p.InsecureSkipVerify = true
+	require.NoError(t, p.Init())
+	connConfig := p.dbConfig.ConnConfig
+	require.NotNil(t, connConfig.TLSConfig)
+	assert.True(t, connConfig.TLSConfig.InsecureSkipVerify)
+	assert.Equal(t, "db1", connConfig.TLSConfig.ServerName)
+	require.Len(t, connConfig.Fallbacks, 2)
+	assert.Equal(t, "db2", connConfig.Fallbacks[0].Host)
+	require.NotNil(t, connConfig.Fallbacks[0].TLSConfig)
+	assert.Equal(t, "db2", connConfig.Fallbacks[0].TLSConfig.ServerName)
+	assert.Equal(t, "/tmp", connConfig.Fallbacks[1].Host)
+	assert.Nil(t, connConfig.Fallbacks[1].TLSConfig)
+
+	p = newPostgresql()
+	p.TLSCA = "/nonexistent/ca.pem"
+	assert.Error(t, p.Init())
+}
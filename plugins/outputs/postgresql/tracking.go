@@ -0,0 +1,58 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Telegraf accepts the metrics of a write once it returns without error, notifying the inputs tracking their delivery,
+// such as queue consumers, once all references to a tracking metric are accepted or rejected. The plugin takes its own
+// references (with Copy) to report the outcome of writes which complete after the write returns, or which drop
+// metrics. Only tracking metrics need them; other metrics are passed through as they are.
+
+// holdMetrics returns references to the metrics, which must be released once written, with acceptMetrics, or
+// otherwise, with releaseMetrics.
+func holdMetrics(metrics []telegraf.Metric) []telegraf.Metric {
+	held := make([]telegraf.Metric, len(metrics))
+	for i, metric := range metrics {
+		if _, ok := metric.(telegraf.TrackingMetric); ok {
+			held[i] = metric.Copy()
+		} else {
+			held[i] = metric
+		}
+	}
+	return held
+}
+
+// acceptMetrics releases the references to the metrics, as written.
+func acceptMetrics(metrics []telegraf.Metric) {
+	for _, metric := range metrics {
+		metric.Accept()
+	}
+}
+
+// releaseMetrics releases the references to the metrics, without reporting an outcome, such as when the metrics are
+// left for telegraf to retry, or were rejected by rejectMetrics.
+func releaseMetrics(metrics []telegraf.Metric) {
+	for _, metric := range metrics {
+		metric.Drop()
+	}
+}
+
+// rejectMetrics reports the metrics as not delivered, with a reference rejected in place, so that the reference the
+// metrics were written through is released as usual.
+func rejectMetrics(metrics []telegraf.Metric) {
+	for _, metric := range metrics {
+		if _, ok := metric.(telegraf.TrackingMetric); ok {
+			metric.Copy().Reject()
+		}
+	}
+}
+
+// dropMetrics drops the metrics, which can't be written due to the permanent error writeErr, rejecting them, and
+// recording them as per dead_letter_table and dead_letter_file.
+func (p *Postgresql) dropMetrics(ctx context.Context, db dbh, metrics []telegraf.Metric, writeErr error) {
+	rejectMetrics(metrics)
+	p.deadLetter(ctx, db, metrics, writeErr)
+}
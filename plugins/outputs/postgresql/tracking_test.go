@@ -0,0 +1,54 @@
+package postgresql
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestTracking(t *testing.T) {
+	var delivered []bool
+	track := func(m telegraf.Metric) telegraf.Metric {
+		m, _ = metric.WithTracking(m, func(info telegraf.DeliveryInfo) {
+			delivered = append(delivered, info.Delivered())
+		})
+		return m
+	}
+
+	p := newPostgresql()
+	p.Logger = NewLogAccumulator(t)
+	require.NoError(t, p.Init())
+
+	// A metric dropped during the write is rejected once telegraf accepts the write.
+	m := track(newMetric(t, "", MSS{}, MSI{"v": 1}))
+	p.dropMetrics(ctx, nil, []telegraf.Metric{m}, fmt.Errorf("permanent"))
+	assert.Empty(t, delivered)
+	m.Accept()
+	assert.Equal(t, []bool{false}, delivered)
+
+	// A held metric is only delivered once its reference is accepted, after telegraf accepts the write.
+	delivered = nil
+	m = track(newMetric(t, "", MSS{}, MSI{"v": 1}))
+	held := holdMetrics([]telegraf.Metric{m})
+	m.Accept()
+	assert.Empty(t, delivered)
+	acceptMetrics(held)
+	assert.Equal(t, []bool{true}, delivered)
+
+	// Releasing a held metric leaves the outcome to telegraf.
+	delivered = nil
+	m = track(newMetric(t, "", MSS{}, MSI{"v": 1}))
+	releaseMetrics(holdMetrics([]telegraf.Metric{m}))
+	assert.Empty(t, delivered)
+	m.Reject()
+	assert.Equal(t, []bool{false}, delivered)
+
+	// Metrics which aren't tracked are held as they are.
+	m = newMetric(t, "", MSS{}, MSI{"v": 1})
+	assert.Same(t, m, holdMetrics([]telegraf.Metric{m})[0])
+}
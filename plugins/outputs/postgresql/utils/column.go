@@ -26,6 +26,8 @@ type Column struct {
 	Type string
 	// the role each column has, helps properly map the metric to the db
 	Role ColumnRole
+	// whether the column is computed by the database (GENERATED ALWAYS AS), and thus can't be written to
+	Generated bool
 }
 
 // ColumnList implements sort.Interface.
@@ -51,3 +53,36 @@ func (cl ColumnList) Swap(i, j int) {
 func (cl ColumnList) Sort() {
 	sort.Sort(cl)
 }
+
+// SortByOrder sorts the columns the same as Sort, except that columns named in order are placed first, in the given
+// order.
+func (cl ColumnList) SortByOrder(order []string) {
+	if len(order) == 0 {
+		cl.Sort()
+		return
+	}
+	positions := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, ok := positions[name]; !ok {
+			positions[name] = i
+		}
+	}
+	sort.Sort(orderedColumnList{ColumnList: cl, positions: positions})
+}
+
+type orderedColumnList struct {
+	ColumnList
+	positions map[string]int
+}
+
+func (ocl orderedColumnList) Less(i, j int) bool {
+	iPos, iOk := ocl.positions[ocl.ColumnList[i].Name]
+	jPos, jOk := ocl.positions[ocl.ColumnList[j].Name]
+	if iOk && jOk {
+		return iPos < jPos
+	}
+	if iOk != jOk {
+		return iOk
+	}
+	return ocl.ColumnList.Less(i, j)
+}
@@ -3,10 +3,12 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"hash"
 	"hash/fnv"
 	"strings"
 	"sync/atomic"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/jackc/pgx/v4"
 
 	"github.com/influxdata/telegraf"
@@ -29,6 +31,47 @@ func FieldListToJSON(fieldList []*telegraf.Field) ([]byte, error) {
 	return json.Marshal(fields)
 }
 
+// FieldListToOrderedJSON is like FieldListToJSON, but the keys of the JSON object are in the order of the field list,
+// rather than sorted.
+func FieldListToOrderedJSON(fieldList []*telegraf.Field) ([]byte, error) {
+	buf := []byte{'{'}
+	for i, field := range fieldList {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		buf = append(buf, value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// MetricToJSON serializes the full metric, including its name & time, as a JSON object.
+func MetricToJSON(metric telegraf.Metric) ([]byte, error) {
+	tags := make(map[string]string, len(metric.TagList()))
+	for _, tag := range metric.TagList() {
+		tags[tag.Key] = tag.Value
+	}
+	fields := make(map[string]interface{}, len(metric.FieldList()))
+	for _, field := range metric.FieldList() {
+		fields[field.Key] = field.Value
+	}
+	return json.Marshal(map[string]interface{}{
+		"name":      metric.Name(),
+		"tags":      tags,
+		"fields":    fields,
+		"timestamp": metric.Time().UnixNano(),
+	})
+}
+
 // QuoteIdentifier returns a sanitized string safe to use in SQL as an identifier
 func QuoteIdentifier(name string) string {
 	return pgx.Identifier{name}.Sanitize()
@@ -69,15 +112,38 @@ func (l PGXLogger) Log(_ context.Context, level pgx.LogLevel, msg string, data m
 }
 
 func GetTagID(metric telegraf.Metric) int64 {
-	hash := fnv.New64a()
+	return getTagID(fnv.New64a(), metric)
+}
+
+// GetTagIDXXHash is GetTagID with the xxHash64 algorithm, which is faster, and commonly available to generate the IDs
+// outside of telegraf.
+func GetTagIDXXHash(metric telegraf.Metric) int64 {
+	return getTagID(xxhash.New(), metric)
+}
+
+// GetTagIDFNV128a is GetTagID with the 128-bit FNV-1a algorithm, for fewer collisions between the IDs of many tag sets.
+// The ID is returned in big-endian byte order.
+func GetTagIDFNV128a(metric telegraf.Metric) [16]byte {
+	hash := fnv.New128a()
+	writeTagSet(hash, metric)
+	var id [16]byte
+	copy(id[:], hash.Sum(nil))
+	return id
+}
+
+func getTagID(hash hash.Hash64, metric telegraf.Metric) int64 {
+	writeTagSet(hash, metric)
+	// Convert to int64 as postgres does not support uint64
+	return int64(hash.Sum64())
+}
+
+func writeTagSet(hash hash.Hash, metric telegraf.Metric) {
 	for _, tag := range metric.TagList() {
 		_, _ = hash.Write([]byte(tag.Key))
 		_, _ = hash.Write([]byte{0})
 		_, _ = hash.Write([]byte(tag.Value))
 		_, _ = hash.Write([]byte{0})
 	}
-	// Convert to int64 as postgres does not support uint64
-	return int64(hash.Sum64())
 }
 
 // WaitGroup is similar to sync.WaitGroup, but allows interruptable waiting (e.g. a timeout).
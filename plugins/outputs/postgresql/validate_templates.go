@@ -0,0 +1,79 @@
+package postgresql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/utils"
+)
+
+// validateTemplates renders all of the templates against a synthetic table, so that a broken template fails Init,
+// rather than the first write creating or modifying a table. Referencing a tag or field the synthetic metric lacks is
+// not considered an error, as that depends on the metrics being written.
+func (p *Postgresql) validateTemplates() error {
+	type templateSet struct {
+		option string
+		tmpls  []*sqltemplate.Template
+		tagTbl bool
+	}
+	sets := []templateSet{
+		{"create_templates", p.CreateTemplates, false},
+		{"create_index_templates", p.CreateIndexTemplates, false},
+		{"post_create_templates", p.PostCreateTemplates, false},
+		{"post_write_templates", p.PostWriteTemplates, false},
+		{"add_column_templates", p.AddColumnTemplates, false},
+		{"widen_column_templates", p.WidenColumnTemplates, false},
+		{"drop_column_templates", p.DropColumnTemplates, false},
+		{"tag_table_create_templates", p.TagTableCreateTemplates, true},
+		{"tag_table_add_column_templates", p.TagTableAddColumnTemplates, true},
+		{"tag_table_post_create_templates", p.TagTablePostCreateTemplates, true},
+	}
+	for i, tt := range p.TableTemplates {
+		prefix := fmt.Sprintf("table_templates entry %d: ", i+1)
+		sets = append(sets,
+			templateSet{prefix + "create_templates", tt.CreateTemplates, false},
+			templateSet{prefix + "create_index_templates", tt.CreateIndexTemplates, false},
+			templateSet{prefix + "post_create_templates", tt.PostCreateTemplates, false},
+			templateSet{prefix + "post_write_templates", tt.PostWriteTemplates, false},
+			templateSet{prefix + "add_column_templates", tt.AddColumnTemplates, false},
+			templateSet{prefix + "tag_table_create_templates", tt.TagTableCreateTemplates, true},
+			templateSet{prefix + "tag_table_add_column_templates", tt.TagTableAddColumnTemplates, true},
+			templateSet{prefix + "tag_table_post_create_templates", tt.TagTablePostCreateTemplates, true},
+		)
+	}
+
+	tagCol := utils.Column{Name: "host", Type: PgText, Role: utils.TagColType}
+	fieldCol := utils.Column{Name: "value", Type: PgDoublePrecision, Role: utils.FieldColType}
+	metricCols := []utils.Column{p.timeColumn(), tagCol, fieldCol}
+	tagCols := []utils.Column{p.tagIDColumn(), tagCol}
+	tagTable := sqltemplate.NewTable(p.Schema, "example"+p.TagTableSuffix, p.dialect.translateColumns(tagCols))
+	// As with update(), the tag table is only available to templates with tags_as_foreign_keys.
+	tmplTagTable := sqltemplate.NewTable("", "", nil)
+	if p.TagsAsForeignKeys {
+		metricCols = []utils.Column{p.timeColumn(), p.tagIDColumn(), fieldCol}
+		tmplTagTable = tagTable
+	}
+	metricTable := sqltemplate.NewTable(p.Schema, "example", p.dialect.translateColumns(metricCols))
+	metric := &sqltemplate.Metric{
+		Name:   "example",
+		Tags:   map[string]string{"host": "example"},
+		Fields: map[string]interface{}{"value": 1.0},
+		Types:  map[string]string{"value": PgDoublePrecision},
+	}
+
+	for _, set := range sets {
+		table, cols := metricTable, metricCols
+		if set.tagTbl {
+			table, cols = tagTable, tagCols
+		}
+		for i, tmpl := range set.tmpls {
+			_, err := tmpl.Render(table, p.dialect.translateColumns(cols), metricTable, tmplTagTable, p.Tablespace,
+				p.ColumnOrder, metric)
+			if err != nil && !strings.Contains(err.Error(), "map has no entry for key") {
+				return fmt.Errorf("%s template %d: %w", set.option, i+1, err)
+			}
+		}
+	}
+	return nil
+}
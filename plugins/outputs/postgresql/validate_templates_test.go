@@ -0,0 +1,64 @@
+package postgresql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/plugins/outputs/postgresql/sqltemplate"
+)
+
+func TestPostgresql_validateTemplates(t *testing.T) {
+	tmpl := func(text string) *sqltemplate.Template {
+		tpl := &sqltemplate.Template{}
+		require.NoError(t, tpl.UnmarshalText([]byte(text)))
+		return tpl
+	}
+
+	p := newPostgresql()
+	p.AddColumnTemplates = []*sqltemplate.Template{
+		tmpl(`ALTER TABLE {{ .table }} ADD COLUMN {{ .columns }}`),
+		tmpl(`ALTER TABLE {{ .table.Nope }}`),
+	}
+	err := p.Init()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "add_column_templates template 2")
+	assert.Contains(t, err.Error(), "Nope")
+
+	p = newPostgresql()
+	p.TableTemplates = []*TableTemplates{{
+		Measurements:    []string{"cpu"},
+		CreateTemplates: []*sqltemplate.Template{tmpl(`{{ .table.Name | regexReplace "(" "" }}`)},
+	}}
+	err = p.Init()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "table_templates entry 1: create_templates template 1")
+
+	// The tags of the metrics being written aren't known in advance.
+	p = newPostgresql()
+	p.CreateTemplates = []*sqltemplate.Template{tmpl(`CREATE TABLE {{ .table }} () TABLESPACE {{ .metric.Tags.region }}`)}
+	require.NoError(t, p.Init())
+
+	p = newPostgresql()
+	p.TagsAsForeignKeys = true
+	p.TagTableCreateTemplates = []*sqltemplate.Template{
+		tmpl(`CREATE TABLE {{ .table }} ({{ .columns }}) -- {{ .metricTable.Name }} {{ .tagTable.Name }}`),
+	}
+	require.NoError(t, p.Init())
+}
+
+func TestPostgresql_validateTemplates_dialects(t *testing.T) {
+	for name := range dialects {
+		for _, tagsAsForeignKeys := range []bool{false, true} {
+			p := newPostgresql()
+			p.Dialect = name
+			p.TagsAsForeignKeys = tagsAsForeignKeys
+			err := p.Init()
+			if err != nil {
+				// Some dialects don't support tags_as_foreign_keys.
+				assert.NotContains(t, err.Error(), "template", "dialect %s", name)
+			}
+		}
+	}
+}
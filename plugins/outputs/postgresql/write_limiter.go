@@ -0,0 +1,73 @@
+package postgresql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// writeLimiter limits the number of write workers writing at once, with adaptive_concurrency. The limit starts at 1,
+// and is raised while writes complete within the target latency and other workers are waiting to write, and lowered
+// when a write exceeds the target latency.
+type writeLimiter struct {
+	// tokens holds a token for each worker which may start writing. Its capacity is the maximum limit.
+	tokens chan struct{}
+	target time.Duration
+
+	mu      sync.Mutex
+	limit   int
+	waiting int
+}
+
+func newWriteLimiter(maxLimit int, target time.Duration) *writeLimiter {
+	l := &writeLimiter{
+		tokens: make(chan struct{}, maxLimit),
+		target: target,
+		limit:  1,
+	}
+	l.tokens <- struct{}{}
+	return l
+}
+
+// acquire waits until the worker may write. It returns false if the context is done first.
+func (l *writeLimiter) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+	l.waiting++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case <-l.tokens:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release is called when the worker has finished writing, with the time the write took, and adjusts the limit. The
+// limit is lowered by keeping the worker's token rather than returning it.
+func (l *writeLimiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case latency > l.target && l.limit > 1:
+		l.limit--
+		return
+	case latency <= l.target && l.waiting > 0 && l.limit < cap(l.tokens):
+		l.limit++
+		l.tokens <- struct{}{}
+	}
+	l.tokens <- struct{}{}
+}
+
+// currentLimit returns the number of workers which may currently write at once.
+func (l *writeLimiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
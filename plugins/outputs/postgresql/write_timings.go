@@ -0,0 +1,52 @@
+package postgresql
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// writeTimings holds the time taken by each stage of writing a table's metrics: matching the table structure to the
+// metrics (including any schema modifications), writing the tag table, and copying the metrics into the table. The
+// times are recorded as internal timing stats per table, and logged at debug level, so that slow writes can be
+// attributed to a stage.
+type writeTimings struct {
+	table string
+	start time.Time
+
+	matchSource time.Duration
+	tagWrite    time.Duration
+	copy        time.Duration
+}
+
+func newWriteTimings(table string) *writeTimings {
+	return &writeTimings{table: table, start: time.Now()}
+}
+
+// lap sets the stage to the time since the previous lap.
+func (wt *writeTimings) lap(stage *time.Duration) {
+	now := time.Now()
+	*stage = now.Sub(wt.start)
+	wt.start = now
+}
+
+// record adds the timings to the internal stats, and logs them.
+func (wt *writeTimings) record(log telegraf.Logger) {
+	tags := map[string]string{"table": wt.table}
+	stages := []struct {
+		field    string
+		duration time.Duration
+	}{
+		{"match_source_time_ns", wt.matchSource},
+		{"tag_write_time_ns", wt.tagWrite},
+		{"copy_time_ns", wt.copy},
+	}
+	for _, stage := range stages {
+		if stage.duration > 0 {
+			selfstat.RegisterTiming("postgresql", stage.field, tags).Incr(stage.duration.Nanoseconds())
+		}
+	}
+	log.Debugf("table '%s' write timings: match_source=%s tag_write=%s copy=%s",
+		wt.table, wt.matchSource, wt.tagWrite, wt.copy)
+}